@@ -0,0 +1,316 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAccountDeletionGrace = 30 * 24 * time.Hour
+	accountPurgeEvery           = time.Hour
+	accountPurgeBatchSize       = 100
+)
+
+type accountDeletion struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code"`
+}
+
+type AccountDeletionResponse struct {
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+type LikeExport struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+// userExport is everything a user can download about themselves. Each field
+// becomes one JSON file in the archive.
+type userExport struct {
+	Profile  UserResponse
+	Chirps   []ChirpResponse
+	Likes    []LikeExport
+	Sessions []SessionResponse
+}
+
+// deleteUserHandler schedules the caller's account for deletion. The password,
+// and a second factor when 2FA is enabled, are asked for again so a stolen
+// access token can't delete an account. Every refresh token is revoked and
+// the caller's access token is denylisted, so no session can be renewed, but
+// access tokens held by other devices stay valid until they expire, as they
+// do after a password change. Nothing is deleted until the grace period is
+// over; logging in again before then cancels the deletion.
+func (cfg *apiConfig) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	params := accountDeletion{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	match, _ := auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	if !match {
+		respondWithError(w, http.StatusForbidden, "password is required to delete the account")
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the account")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	credential, err := qtx.GetTotpCredentialForUpdate(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check the user's two-factor settings")
+		return
+	}
+	if err == nil && credential.ConfirmedAt.Valid {
+		ok, err := checkSecondFactor(r.Context(), qtx, credential, params.Code)
+		if respondToTOTPLockout(w, err) {
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check the two-factor code")
+			return
+		}
+		if !ok {
+			err = tx.Commit()
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't check the two-factor code")
+				return
+			}
+			respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+			return
+		}
+	}
+
+	deletion, err := qtx.ScheduleAccountDeletion(r.Context(), database.ScheduleAccountDeletionParams{
+		UserID:     user.ID,
+		PurgeAfter: time.Now().Add(cfg.deletionGrace),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't schedule the account deletion")
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the user's sessions")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the account")
+		return
+	}
+
+	err = cfg.jwtKeys.Denylist().Deny(r.Context(), caller.ID, caller.ExpiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the access token")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, AccountDeletionResponse{PurgeAfter: deletion.PurgeAfter})
+}
+
+// runAccountPurge deletes accounts whose grace period is over, once at
+// startup and then every accountPurgeEvery.
+func (cfg *apiConfig) runAccountPurge() {
+	ticker := time.NewTicker(accountPurgeEvery)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeDueAccounts(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) purgeDueAccounts(ctx context.Context) {
+	for {
+		userIDs, err := cfg.db.ListDueAccountDeletions(ctx, accountPurgeBatchSize)
+		if err != nil {
+			log.Printf("Error listing accounts to delete: %v", err)
+			return
+		}
+
+		for _, userID := range userIDs {
+			err = cfg.purgeAccount(ctx, userID)
+			if err != nil {
+				log.Printf("Error deleting account %s: %v", userID, err)
+				return
+			}
+		}
+
+		if len(userIDs) < accountPurgeBatchSize {
+			return
+		}
+	}
+}
+
+// purgeAccount hard deletes a user. Chirps, tokens, follows and everything
+// else that references the user go with it through ON DELETE CASCADE.
+func (cfg *apiConfig) purgeAccount(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	mediaKeys, err := qtx.ListUserMediaKeys(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = qtx.RemoveUserLikeCounts(ctx, userID)
+	if err != nil {
+		return err
+	}
+	err = qtx.RemoveUserRechirpCounts(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// The user is only deleted if the deletion is still due, in case they
+	// logged in and cancelled it since it was listed
+	purged, err := qtx.PurgeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if purged == 0 {
+		return nil
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Files go once the rows pointing at them are gone, so a failed
+	// transaction can't leave media that 404s
+	for _, key := range mediaKeys {
+		cfg.deleteMediaFiles(ctx, key.StorageKey, key.ThumbnailKey)
+	}
+	return nil
+}
+
+// writeExportArchive writes export as a ZIP archive with one JSON file per
+// kind of data.
+func writeExportArchive(w io.Writer, export userExport) error {
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", export.Profile},
+		{"chirps.json", export.Chirps},
+		{"likes.json", export.Likes},
+		{"sessions.json", export.Sessions},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(file.data)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// exportUserHandler sends the caller a ZIP of their profile, chirps, likes
+// and sessions. The archive is written straight to the response, so an error
+// part way through can only cut it short.
+func (cfg *apiConfig) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	user, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	export := userExport{
+		Profile:  userResponseFromDB(user),
+		Chirps:   []ChirpResponse{},
+		Likes:    []LikeExport{},
+		Sessions: []SessionResponse{},
+	}
+
+	dbChirps, err := cfg.db.ListUserChirps(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the user's chirps")
+		return
+	}
+	for _, chirp := range dbChirps {
+		export.Chirps = append(export.Chirps, chirpResponseFromDB(chirp))
+	}
+	chirpPtrs := make([]*ChirpResponse, 0, len(export.Chirps))
+	for i := range export.Chirps {
+		chirpPtrs = append(chirpPtrs, &export.Chirps[i])
+	}
+	err = cfg.fillMedia(r.Context(), chirpPtrs)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirps' media")
+		return
+	}
+
+	likes, err := cfg.db.ListUserLikes(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the user's likes")
+		return
+	}
+	for _, like := range likes {
+		export.Likes = append(export.Likes, LikeExport{ChirpID: like.ChirpID, LikedAt: like.CreatedAt})
+	}
+
+	sessions, err := cfg.db.ListActiveSessions(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the user's sessions")
+		return
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, SessionResponse{
+			ID:         session.FamilyID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			Current:    session.FamilyID == caller.SessionID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+	err = writeExportArchive(w, export)
+	if err != nil {
+		log.Printf("Error writing the export for user %s: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestWriteExportArchive(t *testing.T) {
+	userID := uuid.New()
+	chirpID := uuid.New()
+	export := userExport{
+		Profile:  UserResponse{ID: userID, Email: "walt@example.com"},
+		Chirps:   []ChirpResponse{{ID: chirpID, UserID: userID, Body: "Say my name"}},
+		Likes:    []LikeExport{{ChirpID: chirpID, LikedAt: time.Now()}},
+		Sessions: []SessionResponse{},
+	}
+
+	var buf bytes.Buffer
+	err := writeExportArchive(&buf, export)
+	if err != nil {
+		t.Fatalf("writeExportArchive() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("ReadAll(%s) error = %v", f.Name, err)
+		}
+		files[f.Name] = data
+	}
+
+	for _, name := range []string{"profile.json", "chirps.json", "likes.json", "sessions.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Archive is missing %s", name)
+		}
+	}
+
+	profile := UserResponse{}
+	err = json.Unmarshal(files["profile.json"], &profile)
+	if err != nil || profile.ID != userID {
+		t.Errorf("profile.json = %s, want user %s", files["profile.json"], userID)
+	}
+
+	chirps := []ChirpResponse{}
+	err = json.Unmarshal(files["chirps.json"], &chirps)
+	if err != nil || len(chirps) != 1 || chirps[0].Body != "Say my name" {
+		t.Errorf("chirps.json = %s, want the one chirp", files["chirps.json"])
+	}
+
+	// No sessions is an empty list, not null
+	if got := string(bytes.TrimSpace(files["sessions.json"])); got != "[]" {
+		t.Errorf("sessions.json = %s, want []", got)
+	}
+}
+
+func TestDeleteUserHandler(t *testing.T) {
+	cfg := newTestConfig(t)
+	cfg.deletionGrace = 30 * 24 * time.Hour
+
+	hashedPassword, err := auth.HashPassword("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := database.User{ID: uuid.New(), Email: "walt@example.com", HashedPassword: hashedPassword, Role: "user"}
+	purgeAfter := time.Now().Add(cfg.deletionGrace)
+	db := useFakeDB(t, cfg, map[string]fakeQuery{
+		"GetUserByID":                rows(userRow(user)),
+		"GetTotpCredentialForUpdate": rows(),
+		"ScheduleAccountDeletion":    rows([]driver.Value{user.ID.String(), time.Now(), purgeAfter}),
+		"RevokeUserRefreshTokens":    affected(2),
+	})
+
+	caller := auth.AccessToken{UserID: user.ID, SessionID: uuid.New(), Role: auth.RoleUser, ID: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour)}
+	r := httptest.NewRequest("DELETE", "/api/users", strings.NewReader(`{"password": "old-password"}`))
+	r = r.WithContext(withPrincipal(r.Context(), caller))
+	w := httptest.NewRecorder()
+	cfg.deleteUserHandler(w, r)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusAccepted, w.Body)
+	}
+	// No session can be renewed once the refresh tokens are revoked
+	if !db.Ran("RevokeUserRefreshTokens") || !db.Ran("COMMIT") {
+		t.Error("Expected every refresh token to be revoked and committed")
+	}
+	denied, err := cfg.jwtKeys.Denylist().IsDenied(r.Context(), caller.ID)
+	if err != nil || !denied {
+		t.Errorf("IsDenied(caller) = %v, %v, want the caller's access token denylisted", denied, err)
+	}
+}
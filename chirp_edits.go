@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const defaultChirpEditWindow = 15 * time.Minute
+
+// chirpEdit is the body of an edit. Only the text can change, so replies
+// and attachments are rejected rather than silently ignored.
+type chirpEdit struct {
+	Body string `json:"body" validate:"required,max=140"`
+}
+
+type ChirpRevisionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp Id")
+		return
+	}
+
+	params := chirpEdit{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+	moderated, ok := cfg.moderateChirpBody(w, params.Body)
+	if !ok {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+	if !user.IsChirpyRed {
+		respondWithError(w, http.StatusForbidden, "Editing chirps requires Chirpy Red")
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start editing the chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	editing, err := qtx.GetChirpForEdit(r.Context(), database.GetChirpForEditParams{
+		ID:                chirpID,
+		EditWindowSeconds: cfg.editWindow.Seconds(),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Chirp doesn't exist")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirp")
+		return
+	}
+
+	chirp := editing.Chirp
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "Chirps can only be edited by their creators")
+		return
+	}
+
+	if !editing.Editable {
+		respondWithError(w, http.StatusForbidden, "The edit window for this chirp has passed")
+		return
+	}
+
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		ChirpID: chirp.ID,
+		Body:    chirp.Body,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the chirp's previous revision")
+		return
+	}
+
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirp.ID,
+		Body: moderated.Text,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update the chirp")
+		return
+	}
+
+	err = saveChirpEntities(r.Context(), qtx, updated.ID, updated.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update the chirp's hashtags and mentions")
+		return
+	}
+
+	err = saveChirpFlags(r.Context(), qtx, updated.ID, moderated)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't flag the chirp for review")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the edited chirp")
+		return
+	}
+
+	response := chirpResponseFromDB(updated)
+	err = cfg.hydrateChirps(r, []*ChirpResponse{&response})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirp's likes and media")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) getChirpRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp Id")
+		return
+	}
+
+	_, err = cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Chirp doesn't exist")
+		return
+	}
+
+	dbRevisions, err := cfg.db.ListChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirp's revisions")
+		return
+	}
+
+	revisions := []ChirpRevisionResponse{}
+	for _, revision := range dbRevisions {
+		revisions = append(revisions, ChirpRevisionResponse{
+			ID:        revision.ID,
+			ChirpID:   revision.ChirpID,
+			Body:      revision.Body,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, revisions)
+}
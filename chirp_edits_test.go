@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+func TestUpdateChirpHandler(t *testing.T) {
+	userID := uuid.New()
+	chirp := database.Chirp{ID: uuid.New(), UserID: userID, Body: "first draft", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	tests := []struct {
+		name        string
+		body        string
+		chirpyRed   bool
+		editable    bool
+		want        int
+		wantUpdated bool
+	}{
+		{name: "Edit", body: `{"body": "second draft"}`, chirpyRed: true, editable: true, want: http.StatusOK, wantUpdated: true},
+		{name: "Reply field", body: `{"body": "second draft", "in_reply_to": "` + uuid.NewString() + `"}`, chirpyRed: true, editable: true, want: http.StatusBadRequest},
+		{name: "Media field", body: `{"body": "second draft", "media_ids": []}`, chirpyRed: true, editable: true, want: http.StatusBadRequest},
+		{name: "Without Chirpy Red", body: `{"body": "second draft"}`, editable: true, want: http.StatusForbidden},
+		{name: "Edit window passed", body: `{"body": "second draft"}`, chirpyRed: true, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			cfg.editWindow = 15 * time.Minute
+			cfg.moderator = &contentModerator{fileRules: moderation.DefaultRules}
+			if err := cfg.moderator.update(nil); err != nil {
+				t.Fatal(err)
+			}
+
+			var editWindowArg driver.Value
+			updated := chirp
+			updated.Body = "second draft"
+			db := useFakeDB(t, cfg, map[string]fakeQuery{
+				"GetUserByID": rows(userRow(database.User{ID: userID, IsChirpyRed: tt.chirpyRed, Role: string(auth.RoleUser)})),
+				"GetChirpForEdit": func(args []driver.Value) (fakeResult, error) {
+					editWindowArg = args[1]
+					return rows(append(chirpRow(chirp), tt.editable))(args)
+				},
+				"CreateChirpRevision": rows([]driver.Value{uuid.NewString(), chirp.ID.String(), chirp.Body, time.Now()}),
+				"UpdateChirpBody":     rows(chirpRow(updated)),
+				"DeleteChirpHashtags": affected(0),
+				"DeleteChirpMentions": affected(0),
+				"ListMediaForChirps":  rows(),
+				"ListLikedChirpIDs":   rows(),
+			})
+
+			r := httptest.NewRequest("PUT", "/api/chirps/"+chirp.ID.String(), strings.NewReader(tt.body))
+			r.SetPathValue("chirpID", chirp.ID.String())
+			r = r.WithContext(withPrincipal(r.Context(), auth.AccessToken{UserID: userID, Role: auth.RoleUser}))
+			w := httptest.NewRecorder()
+			cfg.updateChirpHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if db.Ran("UpdateChirpBody") != tt.wantUpdated {
+				t.Errorf("UpdateChirpBody ran = %v, want %v", db.Ran("UpdateChirpBody"), tt.wantUpdated)
+			}
+			if db.Ran("GetChirpForEdit") && editWindowArg != float64(15*60) {
+				t.Errorf("edit window = %v, want 900 seconds", editWindowArg)
+			}
+		})
+	}
+}
@@ -2,12 +2,10 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"log"
 	"net/http"
-	"slices"
-	"sort"
-	"strings"
 	"time"
 
 	"github.com/ericksotoe/chirpy/internal/auth"
@@ -16,15 +14,42 @@ import (
 )
 
 type parameters struct {
-	Body string `json:"body"`
+	Body      string      `json:"body" validate:"required,max=140"`
+	InReplyTo *uuid.UUID  `json:"in_reply_to"`
+	MediaIDs  []uuid.UUID `json:"media_ids" validate:"max=4"`
 }
 
 type ChirpResponse struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID           uuid.UUID       `json:"id"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	Body         string          `json:"body"`
+	UserID       uuid.UUID       `json:"user_id"`
+	InReplyTo    *uuid.UUID      `json:"in_reply_to"`
+	LikeCount    int32           `json:"like_count"`
+	RechirpCount int32           `json:"rechirp_count"`
+	LikedByMe    *bool           `json:"liked_by_me,omitempty"`
+	Media        []MediaResponse `json:"media"`
+}
+
+func chirpResponseFromDB(chirp database.Chirp) ChirpResponse {
+	return ChirpResponse{
+		ID:           chirp.ID,
+		CreatedAt:    chirp.CreatedAt,
+		UpdatedAt:    chirp.UpdatedAt,
+		Body:         chirp.Body,
+		UserID:       chirp.UserID,
+		InReplyTo:    nullUUIDPtr(chirp.InReplyTo),
+		LikeCount:    chirp.LikeCount,
+		RechirpCount: chirp.RechirpCount,
+	}
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
 }
 
 type SliceChirpResponse struct {
@@ -33,75 +58,99 @@ type SliceChirpResponse struct {
 
 func (cfg *apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Request) {
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	if !decodeRequest(w, r, &params) {
 		return
 	}
 
-	unverifiedToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+	userID := requestPrincipal(r).UserID
+
+	if !cfg.requireVerifiedEmail(w, r, userID) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(unverifiedToken, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
+	inReplyTo := uuid.NullUUID{}
+	if params.InReplyTo != nil {
+		_, err := cfg.db.GetChirpsByID(r.Context(), *params.InReplyTo)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "The chirp being replied to doesn't exist")
+			return
+		}
+		inReplyTo = uuid.NullUUID{UUID: *params.InReplyTo, Valid: true}
 	}
 
-	if params.Body == "" || userID == uuid.Nil {
-		respondWithError(w, http.StatusBadRequest, "userid or body was left empty")
+	moderated, ok := cfg.moderateChirpBody(w, params.Body)
+	if !ok {
 		return
 	}
 
-	const maxChirpLen = 140
-	if len(params.Body) > maxChirpLen {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+	chirpParams := database.CreateChirpParams{
+		Body:      moderated.Text,
+		UserID:    userID,
+		InReplyTo: inReplyTo}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong when creating chirp")
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
 
-	cleanUpBadWords(&params)
+	chirp, err := qtx.CreateChirp(r.Context(), chirpParams)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong when creating chirp")
+		return
+	}
 
-	chirpParams := database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: userID}
+	err = saveChirpEntities(r.Context(), qtx, chirp.ID, chirp.Body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the chirp's hashtags and mentions")
+		return
+	}
 
-	chirp, err := cfg.db.CreateChirp(context.Background(), chirpParams)
+	err = saveChirpFlags(r.Context(), qtx, chirp.ID, moderated)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong when creating chirp")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't flag the chirp for review")
 		return
 	}
-	respondWithJSON(w, http.StatusCreated, ChirpResponse{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
-}
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
-	type returnErrVal struct {
-		Error string `json:"error"`
+	for position, mediaID := range params.MediaIDs {
+		attached, err := qtx.AttachMediaToChirp(r.Context(), database.AttachMediaToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: sql.NullInt32{Int32: int32(position), Valid: true},
+			ID:       mediaID,
+			UserID:   userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't attach media to the chirp")
+			return
+		}
+		if attached == 0 {
+			respondWithError(w, http.StatusBadRequest, "Media must be your own unattached uploads")
+			return
+		}
 	}
 
-	respBody := returnErrVal{
-		Error: msg,
+	err = recordChirpEvent(r.Context(), qtx, chirpCreatedEvent, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't publish the new chirp")
+		return
 	}
 
-	dat, err := json.Marshal(respBody)
+	err = tx.Commit()
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(500)
+		respondWithError(w, http.StatusInternalServerError, "Something went wrong when creating chirp")
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	w.Write(dat)
+
+	response := chirpResponseFromDB(chirp)
+	err = cfg.fillMedia(r.Context(), []*ChirpResponse{&response})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirp's media")
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, response)
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
@@ -117,65 +166,55 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(dat)
 }
 
-func cleanUpBadWords(params *parameters) {
-	badWords := []string{"kerfuffle", "sharbert", "fornax"}
-	wordsToCheck := params.Body
-	words := strings.Split(wordsToCheck, " ")
-	for i, word := range words {
-		if slices.Contains(badWords, strings.ToLower(word)) {
-			words[i] = "****"
-		}
-	}
-	wordsToCheck = strings.Join(words, " ")
-	params.Body = wordsToCheck
-}
-
 func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
-
-	dbChirps, err := cfg.db.GetChirps(r.Context())
+	page, err := parsePageRequest(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps")
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	authorID := uuid.Nil
+	authorID := uuid.NullUUID{}
 	authorIDString := r.URL.Query().Get("author_id")
 	if authorIDString != "" {
-		authorID, err = uuid.Parse(authorIDString)
+		parsedID, err := uuid.Parse(authorIDString)
 		if err != nil {
 			respondWithError(w, http.StatusBadRequest, "Invalid author ID")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
 	}
 
-	sortOrder := r.URL.Query().Get("sort")
-
-	chirps := []ChirpResponse{}
-	for _, dbChirp := range dbChirps {
-		if authorID != uuid.Nil && dbChirp.UserID != authorID {
-			continue
-		}
-
-		chirps = append(chirps, ChirpResponse{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			UserID:    dbChirp.UserID,
-			Body:      dbChirp.Body,
-		})
-	}
+	cursorCreatedAt, cursorID := page.cursorArgs()
 
-	if sortOrder == "desc" {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
+	var dbChirps []database.Chirp
+	if page.desc {
+		dbChirps, err = cfg.db.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
 		})
 	} else {
-		sort.Slice(chirps, func(i, j int) bool {
-			return chirps[i].CreatedAt.Before(chirps[j].CreatedAt)
+		dbChirps, err = cfg.db.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
 		})
 	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps")
+		return
+	}
+
+	result := buildChirpPage(dbChirps, page)
+	err = cfg.hydrateChirps(r, chirpPointers(result.Chirps))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
 
-	respondWithJSON(w, http.StatusOK, chirps)
+	respondWithJSON(w, http.StatusOK, result)
 }
 
 func (cfg *apiConfig) getChirpsByIDHandler(w http.ResponseWriter, r *http.Request) {
@@ -191,27 +230,18 @@ func (cfg *apiConfig) getChirpsByIDHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, ChirpResponse{
-		ID:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserID:    chirp.UserID,
-	})
-}
-
-func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
+	response := chirpResponseFromDB(chirp)
+	err = cfg.hydrateChirps(r, []*ChirpResponse{&response})
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Access token is malformed or missing")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "malformed / bad signature / expired token")
-		return
-	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
 
 	chirpIDString := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(chirpIDString)
@@ -232,19 +262,52 @@ func (cfg *apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = cfg.db.DeleteChirpsByID(ctx, chirpID)
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting the users chirp")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// The media rows would go with the chirp anyway, but deleting them
+	// first is the only way to learn which files to remove
+	mediaKeys, err := qtx.DeleteChirpMedia(ctx, uuid.NullUUID{UUID: chirpID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting the chirp's media")
+		return
+	}
+
+	// Replies to a deleted chirp are orphaned rather than removed: the
+	// in_reply_to foreign key is ON DELETE SET NULL.
+	err = qtx.DeleteChirpsByID(ctx, chirpID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error deleting the users chirp")
 		return
 	}
 
+	err = recordChirpEvent(ctx, qtx, chirpDeletedEvent, chirp)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error publishing the chirp deletion")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error deleting the users chirp")
+		return
+	}
+
+	for _, key := range mediaKeys {
+		cfg.deleteMediaFiles(ctx, key.StorageKey, key.ThumbnailKey)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type UpgradeEvent struct {
 	Event string `json:"event"`
 	Data  struct {
-		UserID string `json:"user_id"`
+		UserID string `json:"user_id" validate:"uuid"`
 	} `json:"data"`
 }
 
@@ -260,11 +323,8 @@ func (cfg *apiConfig) addChirpyRedHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := UpgradeEvent{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	if !decodeWebhook(w, r, &params) {
 		return
 	}
 
@@ -274,7 +334,7 @@ func (cfg *apiConfig) addChirpyRedHandler(w http.ResponseWriter, r *http.Request
 	}
 	userID, err := uuid.Parse(params.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error chaning the string userid to a uuid")
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID in the webhook event")
 		return
 	}
 
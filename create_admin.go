@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+)
+
+const createAdminUsage = "usage: chirpy create-admin <email> (the password is read from stdin)"
+
+// runCreateAdminCommand bootstraps an admin account. An existing user with
+// the email is promoted and keeps their password; otherwise a new user is
+// created with the password read from the first line of stdin, so it never
+// shows up in the shell history or the process list.
+func runCreateAdminCommand(ctx context.Context, db *sql.DB, stdin io.Reader, args []string) error {
+	if len(args) != 1 || args[0] == "" {
+		return errors.New(createAdminUsage)
+	}
+	email := args[0]
+	if reason := checkEmail(email); reason != "" {
+		return errors.New("email " + reason)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+
+	user, err := q.GetUserUsingEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		password, err := readPassword(stdin)
+		if err != nil {
+			return err
+		}
+		hashedPassword, err := auth.HashPassword(password)
+		if err != nil {
+			return err
+		}
+		user, err = q.CreateUser(ctx, database.CreateUserParams{
+			Email:          email,
+			HashedPassword: hashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	_, err = q.SetUserRole(ctx, database.SetUserRoleParams{
+		ID:   user.ID,
+		Role: string(auth.RoleAdmin),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s (%s) is now an admin\n", user.Email, user.ID)
+	return nil
+}
+
+func readPassword(stdin io.Reader) (string, error) {
+	line, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("no password given on stdin")
+	}
+	if reason := checkPassword(password); reason != "" {
+		return "", errors.New("password " + reason)
+	}
+	return password, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+)
+
+// dbDenylist is the auth.Denylist shared by every instance through Postgres.
+type dbDenylist struct {
+	db *database.Queries
+}
+
+func (d dbDenylist) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	// Logouts are rare next to lookups, so expired rows are cleaned up here
+	// rather than by a background job
+	err := d.db.DeleteExpiredAccessTokenDenials(ctx)
+	if err != nil {
+		return err
+	}
+	return d.db.DenyAccessToken(ctx, database.DenyAccessTokenParams{
+		Jti:       tokenID,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (d dbDenylist) IsDenied(ctx context.Context, tokenID string) (bool, error) {
+	return d.db.IsAccessTokenDenied(ctx, tokenID)
+}
+
+// newDenylist picks the access token denylist from TOKEN_DENYLIST. The
+// in-memory one only works when a single instance serves the API.
+func newDenylist(db *database.Queries) (auth.Denylist, error) {
+	switch kind := os.Getenv("TOKEN_DENYLIST"); kind {
+	case "", "postgres":
+		return dbDenylist{db: db}, nil
+	case "memory":
+		return auth.NewMemoryDenylist(), nil
+	default:
+		return nil, fmt.Errorf("unknown TOKEN_DENYLIST %q", kind)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/mail"
+	"github.com/google/uuid"
+)
+
+const emailVerificationLifetime = 24 * time.Hour
+
+type verifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// sendVerificationEmail mails a signed link proving the user can read mail
+// sent to email. The link is only good for that address, so a later change
+// of address makes older links useless.
+func (cfg *apiConfig) sendVerificationEmail(userID uuid.UUID, email string) error {
+	token, err := auth.MakeEmailVerificationToken(userID, email, cfg.jwtKeys, emailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+
+	link := cfg.baseURL + "/app/verify-email?token=" + url.QueryEscape(token)
+	cfg.sendMail(mail.Message{
+		To:      email,
+		Subject: "Confirm your email address for Chirpy",
+		Body: fmt.Sprintf("Open this link within a day to confirm this address for your Chirpy account:\n%s\n\n"+
+			"If you didn't sign up or change your email on Chirpy, you can ignore this email.\n", link),
+	})
+	return nil
+}
+
+func (cfg *apiConfig) verifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	params := verifyEmailRequest{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	userID, email, err := auth.ValidateEmailVerificationToken(params.Token, cfg.jwtKeys)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Verification link is invalid or expired")
+		return
+	}
+
+	// Confirming a pending address also makes it the account's email
+	user, err := cfg.db.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		Email: email,
+		ID:    userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Verification link is for an address the account no longer uses")
+		return
+	}
+	if respondToUserConflict(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't verify the email address")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userResponseFromDB(user))
+}
+
+// resendVerificationHandler sends a new link for the pending address, or for
+// the current one if it was never verified.
+func (cfg *apiConfig) resendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	email := user.Email
+	if user.PendingEmail.Valid {
+		email = user.PendingEmail.String
+	} else if user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Email address is already verified")
+		return
+	}
+
+	err = cfg.sendVerificationEmail(user.ID, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't send the verification email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// requireVerifiedEmail reports whether userID may post when
+// REQUIRE_VERIFIED_EMAIL is on, writing the error response itself if not.
+func (cfg *apiConfig) requireVerifiedEmail(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	if !cfg.verifiedOnly {
+		return true
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return false
+	}
+	if !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before posting chirps")
+		return false
+	}
+	return true
+}
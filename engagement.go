@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// fillLikedByMe sets LikedByMe on every chirp for the given user with a
+// single query.
+func (cfg *apiConfig) fillLikedByMe(ctx context.Context, userID uuid.UUID, chirps []*ChirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	likedIDs, err := cfg.db.ListLikedChirpIDs(ctx, database.ListLikedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	for _, chirp := range chirps {
+		likedByMe := liked[chirp.ID]
+		chirp.LikedByMe = &likedByMe
+	}
+	return nil
+}
+
+// markLikedForCaller fills in LikedByMe when the request is authenticated
+// and leaves the chirps untouched otherwise.
+func (cfg *apiConfig) markLikedForCaller(r *http.Request, chirps []*ChirpResponse) error {
+	caller, ok := principalFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	return cfg.fillLikedByMe(r.Context(), caller.UserID, chirps)
+}
+
+func chirpPointers(chirps []ChirpResponse) []*ChirpResponse {
+	pointers := make([]*ChirpResponse, 0, len(chirps))
+	for i := range chirps {
+		pointers = append(pointers, &chirps[i])
+	}
+	return pointers
+}
+
+type engagementAction func(ctx context.Context, userID, chirpID uuid.UUID) error
+
+// engagementHandler wraps the like, unlike, rechirp and un-rechirp actions,
+// which all act for the caller on the {chirpID} path value.
+func (cfg *apiConfig) engagementHandler(action engagementAction) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := requestPrincipal(r).UserID
+
+		chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid chirp Id")
+			return
+		}
+
+		_, err = cfg.db.GetChirpsByID(r.Context(), chirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Chirp doesn't exist")
+			return
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirp")
+			return
+		}
+
+		err = action(r.Context(), userID, chirpID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't update the chirp")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (cfg *apiConfig) likeChirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return cfg.db.LikeChirp(ctx, database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+}
+
+func (cfg *apiConfig) unlikeChirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return cfg.db.UnlikeChirp(ctx, database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+}
+
+func (cfg *apiConfig) rechirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return cfg.db.Rechirp(ctx, database.RechirpParams{UserID: userID, ChirpID: chirpID})
+}
+
+func (cfg *apiConfig) undoRechirp(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return cfg.db.UndoRechirp(ctx, database.UndoRechirpParams{UserID: userID, ChirpID: chirpID})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxHashtagLen = 50
+
+var (
+	// A tag or mention only starts at the beginning of the body or after a
+	// character that can't be part of a word, so emails aren't mentions.
+	hashtagPattern  = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#@])#([\p{L}\p{N}_]+)`)
+	mentionPattern  = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_#@])@([A-Za-z0-9_]+)`)
+	usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+)
+
+// extractHashtags returns the distinct, lowercased hashtags in a chirp body
+// in the order they first appear.
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if len([]rune(tag)) > maxHashtagLen || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// extractMentions returns the distinct, lowercased usernames mentioned in a
+// chirp body in the order they first appear.
+func extractMentions(body string) []string {
+	usernames := []string{}
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		username := strings.ToLower(match[1])
+		if !usernamePattern.MatchString(username) || seen[username] {
+			continue
+		}
+		seen[username] = true
+		usernames = append(usernames, username)
+	}
+	return usernames
+}
+
+// normalizeUsername lowercases a requested username and checks it only uses
+// characters that mentions can match.
+func normalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return "", errors.New("username must be 3-30 letters, numbers or underscores")
+	}
+	return username, nil
+}
+
+// saveChirpEntities replaces the hashtags and mentions stored for a chirp
+// with the ones found in its current body. Mentions of usernames that don't
+// exist are dropped.
+func saveChirpEntities(ctx context.Context, q *database.Queries, chirpID uuid.UUID, body string) error {
+	err := q.DeleteChirpHashtags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	err = q.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+
+	tags := extractHashtags(body)
+	if len(tags) > 0 {
+		err = q.AddChirpHashtags(ctx, database.AddChirpHashtagsParams{
+			ChirpID: chirpID,
+			Tags:    tags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	usernames := extractMentions(body)
+	if len(usernames) > 0 {
+		err = q.AddChirpMentions(ctx, database.AddChirpMentionsParams{
+			ChirpID:   chirpID,
+			Usernames: usernames,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cfg *apiConfig) getTagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Tag can't be empty")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var dbChirps []database.Chirp
+	if page.desc {
+		dbChirps, err = cfg.db.ListTagChirpsDesc(r.Context(), database.ListTagChirpsDescParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListTagChirpsAsc(r.Context(), database.ListTagChirpsAscParams{
+			Tag:             tag,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve chirps for the tag")
+		return
+	}
+
+	result := buildChirpPage(dbChirps, page)
+	err = cfg.hydrateChirps(r, chirpPointers(result.Chirps))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (cfg *apiConfig) getUserMentionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var dbChirps []database.Chirp
+	if page.desc {
+		dbChirps, err = cfg.db.ListMentionChirpsDesc(r.Context(), database.ListMentionChirpsDescParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListMentionChirpsAsc(r.Context(), database.ListMentionChirpsAscParams{
+			UserID:          userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the user's mentions")
+		return
+	}
+
+	result := buildChirpPage(dbChirps, page)
+	err = cfg.hydrateChirps(r, chirpPointers(result.Chirps))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
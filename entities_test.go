@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "no hashtags",
+			input:    "just a normal chirp",
+			expected: []string{},
+		},
+		{
+			name:     "hashtags are lowercased and deduplicated",
+			input:    "#Go is great #go #gophers",
+			expected: []string{"go", "gophers"},
+		},
+		{
+			name:     "punctuation ends a hashtag",
+			input:    "loving #golang, and #sql!",
+			expected: []string{"golang", "sql"},
+		},
+		{
+			name:     "hash inside a word is ignored",
+			input:    "issue#42 is fixed",
+			expected: []string{},
+		},
+		{
+			name:     "unicode hashtag",
+			input:    "#Café time",
+			expected: []string{"café"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractHashtags(tt.input)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("extractHashtags(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "mentions are lowercased and deduplicated",
+			input:    "hey @Alice and @bob_99, cc @alice",
+			expected: []string{"alice", "bob_99"},
+		},
+		{
+			name:     "email addresses are not mentions",
+			input:    "mail me at someone@example.com",
+			expected: []string{},
+		},
+		{
+			name:     "too short to be a username",
+			input:    "@ab said hi",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractMentions(tt.input)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("extractMentions(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+)
+
+// fakeResult is what a fakeQuery answers: rows for queries that return
+// them, or the number of rows affected for the ones that don't.
+type fakeResult struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// fakeQuery answers one sqlc query, named by its "-- name:" comment.
+type fakeQuery func(args []driver.Value) (fakeResult, error)
+
+// fakeDB is a database/sql driver for handler tests. Each query is answered
+// by the fakeQuery registered under its name, and every query that ran is
+// recorded, along with COMMIT and ROLLBACK, so tests can check what a
+// handler did. Queries without a fakeQuery fail.
+type fakeDB struct {
+	mu      sync.Mutex
+	queries map[string]fakeQuery
+	ran     []string
+}
+
+// useFakeDB points cfg at a fakeDB answering queries.
+func useFakeDB(t *testing.T, cfg *apiConfig, queries map[string]fakeQuery) *fakeDB {
+	t.Helper()
+	db := &fakeDB{queries: queries}
+	conn := sql.OpenDB(db)
+	t.Cleanup(func() { conn.Close() })
+	cfg.conn = conn
+	cfg.db = database.New(conn)
+	return db
+}
+
+// rows returns a fakeQuery answering with rows of the given values.
+func rows(values ...[]driver.Value) fakeQuery {
+	return func([]driver.Value) (fakeResult, error) {
+		result := fakeResult{rows: values}
+		if len(values) > 0 {
+			for i := range values[0] {
+				result.columns = append(result.columns, fmt.Sprintf("column%d", i))
+			}
+		}
+		return result, nil
+	}
+}
+
+// affected returns a fakeQuery for statements that return no rows.
+func affected(n int64) fakeQuery {
+	return func([]driver.Value) (fakeResult, error) {
+		return fakeResult{affected: n}, nil
+	}
+}
+
+// Ran reports whether the query named name ran.
+func (db *fakeDB) Ran(name string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, ran := range db.ran {
+		if ran == name {
+			return true
+		}
+	}
+	return false
+}
+
+var queryName = regexp.MustCompile(`^-- name: (\w+)`)
+
+func (db *fakeDB) run(query string, args []driver.NamedValue) (fakeResult, error) {
+	name := query
+	if match := queryName.FindStringSubmatch(query); match != nil {
+		name = match[1]
+	}
+
+	db.mu.Lock()
+	db.ran = append(db.ran, name)
+	answer, ok := db.queries[name]
+	db.mu.Unlock()
+	if !ok {
+		return fakeResult{}, fmt.Errorf("fakeDB: unexpected query %s", name)
+	}
+
+	values := make([]driver.Value, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	return answer(values)
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return fakeDriver{db} }
+
+type fakeDriver struct{ db *fakeDB }
+
+func (d fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{d.db}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("fakeDB: prepared statements aren't supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx(c), nil }
+
+func (c fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx(c), nil
+}
+
+func (c fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{result: result}, nil
+}
+
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.run(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+type fakeTx fakeConn
+
+func (tx fakeTx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.ran = append(tx.db.ran, "COMMIT")
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.ran = append(tx.db.ran, "ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
+
+// userRow is the row the users queries that select every column return for
+// user.
+func userRow(user database.User) []driver.Value {
+	return []driver.Value{
+		user.ID.String(), user.CreatedAt, user.UpdatedAt, user.Email, user.HashedPassword,
+		user.IsChirpyRed, nullValue(user.Username), nullValue(user.EmailVerifiedAt),
+		nullValue(user.PendingEmail), user.DisplayName, user.Bio, user.Role,
+	}
+}
+
+// chirpRow is the row the chirps queries that select every column return for
+// chirp.
+func chirpRow(chirp database.Chirp) []driver.Value {
+	return []driver.Value{
+		chirp.ID.String(), chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID.String(),
+		chirp.SearchVector, nullValue(chirp.InReplyTo), int64(chirp.LikeCount), int64(chirp.RechirpCount),
+	}
+}
+
+func nullValue(v driver.Valuer) driver.Value {
+	value, err := v.Value()
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
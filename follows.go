@@ -0,0 +1,284 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// PublicUserResponse is the view of a user that other users are allowed to
+// see, so it leaves out the email address.
+type PublicUserResponse struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Username    *string   `json:"username"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+// UserPage is the envelope returned by the follower and following listings.
+// NextCursor is null once the last page has been reached.
+type UserPage struct {
+	Users      []PublicUserResponse `json:"users"`
+	NextCursor *string              `json:"next_cursor"`
+}
+
+// followPageRow is a row of any of the follower and following listings. They
+// are paged by when the follow happened rather than when the user signed up.
+type followPageRow struct {
+	User       database.User
+	FollowedAt time.Time
+}
+
+func publicUserFromDB(dbUser database.User) PublicUserResponse {
+	return PublicUserResponse{
+		ID:          dbUser.ID,
+		CreatedAt:   dbUser.CreatedAt,
+		Username:    nullStringPtr(dbUser.Username),
+		DisplayName: dbUser.DisplayName,
+		Bio:         dbUser.Bio,
+		IsChirpyRed: dbUser.IsChirpyRed,
+	}
+}
+
+// buildUserPage trims the extra row fetched by fetchLimit and fills in the
+// cursor for the next page, like buildChirpPage.
+func buildUserPage(rows []followPageRow, page pageRequest) UserPage {
+	result := UserPage{Users: []PublicUserResponse{}}
+
+	hasMore := len(rows) > int(page.limit)
+	if hasMore {
+		rows = rows[:page.limit]
+	}
+
+	for _, row := range rows {
+		result.Users = append(result.Users, publicUserFromDB(row.User))
+	}
+
+	if hasMore {
+		last := rows[len(rows)-1]
+		next := encodeCursor(chirpCursor{CreatedAt: last.FollowedAt, ID: last.User.ID})
+		result.NextCursor = &next
+	}
+
+	return result
+}
+
+// followTarget returns the caller and validates the {userID} path value
+// shared by the follow and unfollow endpoints. It writes the error response
+// itself and reports whether the handler should continue.
+func (cfg *apiConfig) followTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	followerID := requestPrincipal(r).UserID
+
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	if followerID == followeeID {
+		respondWithError(w, http.StatusBadRequest, "Users can't follow themselves")
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return followerID, followeeID, true
+}
+
+func (cfg *apiConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.GetUserByID(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User to follow doesn't exist")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't look up the user to follow")
+		return
+	}
+
+	err = cfg.db.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't follow the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	followerID, followeeID, ok := cfg.followTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't unfollow the user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseFollowPageRequest reads the {userID} path value and the page query
+// parameters shared by the follower and following listings. It writes the
+// error response itself and reports whether the handler should continue.
+func parseFollowPageRequest(w http.ResponseWriter, r *http.Request) (uuid.UUID, pageRequest, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return uuid.Nil, pageRequest{}, false
+	}
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return uuid.Nil, pageRequest{}, false
+	}
+	return userID, page, true
+}
+
+// getFollowersHandler lists the users following {userID}, ordered by when
+// they followed.
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, page, ok := parseFollowPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var rows []followPageRow
+	if page.desc {
+		dbRows, err := cfg.db.ListFollowersDesc(r.Context(), database.ListFollowersDescParams{
+			FolloweeID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followers")
+			return
+		}
+		for _, row := range dbRows {
+			rows = append(rows, followPageRow(row))
+		}
+	} else {
+		dbRows, err := cfg.db.ListFollowersAsc(r.Context(), database.ListFollowersAscParams{
+			FolloweeID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followers")
+			return
+		}
+		for _, row := range dbRows {
+			rows = append(rows, followPageRow(row))
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, buildUserPage(rows, page))
+}
+
+// getFollowingHandler lists the users {userID} follows, ordered by when they
+// were followed.
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	userID, page, ok := parseFollowPageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var rows []followPageRow
+	if page.desc {
+		dbRows, err := cfg.db.ListFollowingDesc(r.Context(), database.ListFollowingDescParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followed users")
+			return
+		}
+		for _, row := range dbRows {
+			rows = append(rows, followPageRow(row))
+		}
+	} else {
+		dbRows, err := cfg.db.ListFollowingAsc(r.Context(), database.ListFollowingAscParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followed users")
+			return
+		}
+		for _, row := range dbRows {
+			rows = append(rows, followPageRow(row))
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, buildUserPage(rows, page))
+}
+
+func (cfg *apiConfig) getTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	page, err := parsePageRequest(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cursorCreatedAt, cursorID := page.cursorArgs()
+
+	var dbChirps []database.Chirp
+	if page.desc {
+		dbChirps, err = cfg.db.ListTimelineDesc(r.Context(), database.ListTimelineDescParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	} else {
+		dbChirps, err = cfg.db.ListTimelineAsc(r.Context(), database.ListTimelineAscParams{
+			FollowerID:      userID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			RowLimit:        page.fetchLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the timeline")
+		return
+	}
+
+	result := buildChirpPage(dbChirps, page)
+	err = cfg.hydrateChirps(r, chirpPointers(result.Chirps))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
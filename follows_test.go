@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestBuildUserPage(t *testing.T) {
+	followedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rows := []followPageRow{}
+	for i := range 3 {
+		rows = append(rows, followPageRow{
+			User:       database.User{ID: uuid.New(), CreatedAt: followedAt.AddDate(-1, 0, 0)},
+			FollowedAt: followedAt.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	page := buildUserPage(rows, pageRequest{limit: 2})
+	if len(page.Users) != 2 || page.NextCursor == nil {
+		t.Fatalf("buildUserPage() = %+v, want two users and a cursor", page)
+	}
+	// The cursor points at when the last user was followed, not when they
+	// signed up
+	cursor, err := decodeCursor(*page.NextCursor)
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !cursor.CreatedAt.Equal(rows[1].FollowedAt) || cursor.ID != rows[1].User.ID {
+		t.Errorf("cursor = %+v, want the second row's follow", cursor)
+	}
+
+	last := buildUserPage(rows[2:], pageRequest{limit: 2})
+	if len(last.Users) != 1 || last.NextCursor != nil {
+		t.Errorf("buildUserPage() = %+v, want one user and no cursor", last)
+	}
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"net/http"
@@ -37,52 +39,134 @@ func CheckPasswordHash(password, hash string) (bool, error) {
 	return match, nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		Subject:   userID.String(),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+// sessionClaims are the claims in an access token. SessionID is the refresh
+// token family the access token was issued for, so the API can tell which
+// session a request comes from. Role is the user's role when the token was
+// issued; a role change takes effect at the next refresh.
+type sessionClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Role      Role   `json:"role,omitempty"`
+}
+
+const tokenIssuer = "chirpy"
+
+func MakeJWT(userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return MakeSessionJWT(userID, uuid.Nil, RoleUser, keys, expiresIn)
+}
+
+// MakeSessionJWT is MakeJWT for an access token tied to a session and
+// carrying the user's role. A nil sessionID leaves the sid claim out.
+func MakeSessionJWT(userID, sessionID uuid.UUID, role Role, keys *KeySet, expiresIn time.Duration) (string, error) {
+	claims := sessionClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{keys.audience},
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+		Role: role,
+	}
+	if sessionID != uuid.Nil {
+		claims.SessionID = sessionID.String()
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedJWT, err := token.SignedString([]byte(tokenSecret))
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.id
+	signedJWT, err := token.SignedString(keys.signer)
 	if err != nil {
 		return "", err
 	}
 	return signedJWT, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	// Prepare a claims struct to be populated by ParseWithClaims
-	claims := jwt.RegisteredClaims{}
+// AccessToken is what ParseAccessToken learns from a valid access token.
+type AccessToken struct {
+	UserID uuid.UUID
+	// SessionID is the session the token was issued for, or uuid.Nil for
+	// tokens without a sid claim.
+	SessionID uuid.UUID
+	Role      Role
+	ID        string
+	ExpiresAt time.Time
+}
 
-	// Parse and validate the token, filling in the claims struct
-	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (any, error) {
-		// Return the same key type that was used to sign the token
-		return []byte(tokenSecret), nil
-	})
+func ValidateJWT(ctx context.Context, tokenString string, keys *KeySet) (uuid.UUID, error) {
+	token, err := ParseAccessToken(ctx, tokenString, keys)
 	if err != nil {
 		return uuid.Nil, err
 	}
+	return token.UserID, nil
+}
 
-	// Ensure the token was issued by our application
-	if claims.Issuer != "chirpy" {
-		return uuid.Nil, errors.New("invalid issuer")
+// ParseAccessToken is ValidateJWT that returns everything the API needs to
+// know about the token rather than just the user.
+func ParseAccessToken(ctx context.Context, tokenString string, keys *KeySet) (AccessToken, error) {
+	// Prepare a claims struct to be populated by ParseWithClaims
+	claims := sessionClaims{}
+
+	// Parse and validate the token, filling in the claims struct. The key is
+	// picked by kid, and the issuer, audience and algorithm all have to match.
+	token, err := jwt.ParseWithClaims(tokenString, &claims, keys.lookupKey,
+		jwt.WithValidMethods(allowedAlgorithms),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(keys.audience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return AccessToken{}, err
 	}
 
 	// Extract the subject (user ID string) from the token claims
 	idString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
 
 	// Parse the subject string into a UUID
 	id, err := uuid.Parse(idString)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessToken{}, err
 	}
-	return id, nil
+
+	sessionID := uuid.Nil
+	if claims.SessionID != "" {
+		sessionID, err = uuid.Parse(claims.SessionID)
+		if err != nil {
+			return AccessToken{}, errors.New("invalid session ID")
+		}
+	}
+
+	// Tokens issued before roles existed carry no role claim
+	role := RoleUser
+	if claims.Role != "" {
+		role, err = ParseRole(string(claims.Role))
+		if err != nil {
+			return AccessToken{}, err
+		}
+	}
+
+	// Tokens that were logged out stay on the denylist until they expire
+	if claims.ID == "" {
+		return AccessToken{}, errors.New("token has no ID")
+	}
+	denied, err := keys.denylist.IsDenied(ctx, claims.ID)
+	if err != nil {
+		return AccessToken{}, err
+	}
+	if denied {
+		return AccessToken{}, ErrTokenRevoked
+	}
+
+	return AccessToken{
+		UserID:    id,
+		SessionID: sessionID,
+		Role:      role,
+		ID:        claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
@@ -131,6 +215,15 @@ func MakeRefreshToken() (string, error) {
 	return encodedString, nil
 }
 
+// HashRefreshToken returns the hex encoded SHA-256 digest of a refresh token.
+// Only the digest is stored, so a copy of the database can't be used to
+// refresh sessions. Refresh tokens are 256 random bits, so an unsalted fast
+// hash is enough here, unlike for passwords.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	api, err := GetBearerApi(headers)
 	if err != nil {
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"testing"
 	"time"
@@ -74,33 +76,43 @@ func TestCheckPasswordHash(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	keys := newTestKeySet(t, "chirpy")
+	otherKeys := newTestKeySet(t, "chirpy")
+	validToken, _ := MakeJWT(userID, keys, time.Hour)
+	expiredToken, _ := MakeJWT(userID, keys, -time.Minute)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "Valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		{
 			name:        "Invalid token",
 			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
 		{
-			name:        "Wrong secret",
+			name:        "Unknown signing key",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keys:        otherKeys,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Expired token",
+			tokenString: expiredToken,
+			keys:        keys,
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
@@ -108,7 +120,7 @@ func TestValidateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(context.Background(), tt.tokenString, tt.keys)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -120,6 +132,94 @@ func TestValidateJWT(t *testing.T) {
 	}
 }
 
+func TestParseAccessToken(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	keys := newTestKeySet(t, "chirpy")
+
+	sessionToken, _ := MakeSessionJWT(userID, sessionID, RoleModerator, keys, time.Hour)
+	got, err := ParseAccessToken(context.Background(), sessionToken, keys)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if got.UserID != userID || got.SessionID != sessionID {
+		t.Errorf("ParseAccessToken() = %v, %v, want %v, %v", got.UserID, got.SessionID, userID, sessionID)
+	}
+	if got.Role != RoleModerator {
+		t.Errorf("ParseAccessToken() role = %q, want %q", got.Role, RoleModerator)
+	}
+	if got.ID == "" {
+		t.Error("Expected the token to have a jti")
+	}
+
+	// Tokens without a session still validate, with a nil session ID
+	plainToken, _ := MakeJWT(userID, keys, time.Hour)
+	got, err = ParseAccessToken(context.Background(), plainToken, keys)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if got.UserID != userID || got.SessionID != uuid.Nil {
+		t.Errorf("ParseAccessToken() = %v, %v, want %v, %v", got.UserID, got.SessionID, userID, uuid.Nil)
+	}
+
+	// A role the API doesn't know about is rejected rather than ignored
+	unknownRole, _ := MakeSessionJWT(userID, sessionID, Role("superuser"), keys, time.Hour)
+	_, err = ParseAccessToken(context.Background(), unknownRole, keys)
+	if err == nil {
+		t.Error("Expected a token with an unknown role to be rejected")
+	}
+}
+
+func TestValidateJWTDenylist(t *testing.T) {
+	// Setup
+	userID := uuid.New()
+	keys := newTestKeySet(t, "chirpy")
+	loggedOut, _ := MakeJWT(userID, keys, time.Hour)
+	stillValid, _ := MakeJWT(userID, keys, time.Hour)
+
+	token, err := ParseAccessToken(context.Background(), loggedOut, keys)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+
+	// Execute
+	err = keys.Denylist().Deny(context.Background(), token.ID, token.ExpiresAt)
+	if err != nil {
+		t.Fatalf("Deny() error = %v", err)
+	}
+
+	// Assertions
+	if _, err := ValidateJWT(context.Background(), loggedOut, keys); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected ErrTokenRevoked for a denied token, got %v", err)
+	}
+	if _, err := ValidateJWT(context.Background(), stillValid, keys); err != nil {
+		t.Errorf("Expected other tokens to stay valid, got %v", err)
+	}
+}
+
+func TestMemoryDenylistExpiry(t *testing.T) {
+	now := time.Now()
+	denylist := NewMemoryDenylist()
+	denylist.now = func() time.Time { return now }
+
+	denylist.Deny(context.Background(), "short", now.Add(time.Minute))
+	denylist.Deny(context.Background(), "long", now.Add(time.Hour))
+
+	now = now.Add(2 * time.Minute)
+	if denied, _ := denylist.IsDenied(context.Background(), "short"); denied {
+		t.Error("Expected an entry to be dropped once its token expired")
+	}
+	if denied, _ := denylist.IsDenied(context.Background(), "long"); !denied {
+		t.Error("Expected an entry to stay until its token expires")
+	}
+
+	// Expired entries are pruned on the next Deny
+	denylist.Deny(context.Background(), "another", now.Add(time.Hour))
+	if _, ok := denylist.entries["short"]; ok {
+		t.Error("Expected the expired entry to be pruned")
+	}
+}
+
 func TestGetBearerToken_Success(t *testing.T) {
 	// Setup headers
 	headers := http.Header{}
@@ -153,3 +253,24 @@ func TestGetBearerToken_NoHeader(t *testing.T) {
 		t.Errorf("Expected empty string, got %s", token)
 	}
 }
+
+func TestHashRefreshToken(t *testing.T) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken() error = %v", err)
+	}
+
+	hash := HashRefreshToken(token)
+	if len(hash) != 64 {
+		t.Errorf("Expected a 64 character hex digest, got %d characters", len(hash))
+	}
+	if hash == token {
+		t.Error("Expected the digest to differ from the token")
+	}
+	if HashRefreshToken(token) != hash {
+		t.Error("Expected hashing the same token twice to give the same digest")
+	}
+	if HashRefreshToken(token+"x") == hash {
+		t.Error("Expected different tokens to give different digests")
+	}
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// Denylist records access tokens that were revoked before they expired, by
+// their jti claim. Entries only need to outlive the token, so each one is
+// kept until the token's own expiry.
+type Denylist interface {
+	Deny(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsDenied(ctx context.Context, tokenID string) (bool, error)
+}
+
+// MemoryDenylist is a Denylist for a single instance. Revocations are lost on
+// restart and aren't seen by other instances.
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	now     func() time.Time
+}
+
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: map[string]time.Time{}, now: time.Now}
+}
+
+func (d *MemoryDenylist) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Drop expired entries here so the map can't grow without bound
+	now := d.now()
+	for id, expiry := range d.entries {
+		if !expiry.After(now) {
+			delete(d.entries, id)
+		}
+	}
+	if expiresAt.After(now) {
+		d.entries[tokenID] = expiresAt
+	}
+	return nil
+}
+
+func (d *MemoryDenylist) IsDenied(ctx context.Context, tokenID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiry, ok := d.entries[tokenID]
+	return ok && expiry.After(d.now()), nil
+}
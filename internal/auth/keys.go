@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const minRSAKeyBits = 2048
+
+// allowedAlgorithms are the only algorithms ValidateJWT accepts. HS256 is
+// left out on purpose so a published public key can never be used as an
+// HMAC secret.
+var allowedAlgorithms = []string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}
+
+type verifyKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// KeySet holds the keys for access tokens. The active key signs new tokens;
+// retired keys are kept only to verify tokens signed before a rotation, until
+// those tokens expire.
+type KeySet struct {
+	audience string
+	signer   crypto.Signer
+	active   verifyKey
+	keys     map[string]verifyKey
+	order    []string
+	denylist Denylist
+}
+
+// NewKeySet builds a KeySet that signs with active and also accepts tokens
+// signed by any of the retired keys. Key IDs are RFC 7638 thumbprints, so
+// they don't need to be configured and stay the same across restarts. Until
+// UseDenylist is called, revoked tokens are tracked in memory.
+func NewKeySet(audience string, active crypto.Signer, retired ...crypto.PublicKey) (*KeySet, error) {
+	if audience == "" {
+		return nil, errors.New("audience can't be empty")
+	}
+
+	activeKey, err := newVerifyKey(active.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{
+		audience: audience,
+		signer:   active,
+		active:   activeKey,
+		keys:     map[string]verifyKey{activeKey.id: activeKey},
+		order:    []string{activeKey.id},
+		denylist: NewMemoryDenylist(),
+	}
+	for _, public := range retired {
+		key, err := newVerifyKey(public)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := ks.keys[key.id]; ok {
+			continue
+		}
+		ks.keys[key.id] = key
+		ks.order = append(ks.order, key.id)
+	}
+	return ks, nil
+}
+
+func newVerifyKey(public crypto.PublicKey) (verifyKey, error) {
+	var method jwt.SigningMethod
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSAKeyBits {
+			return verifyKey{}, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		method = jwt.SigningMethodRS256
+	default:
+		return verifyKey{}, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	id, err := KeyID(public)
+	if err != nil {
+		return verifyKey{}, err
+	}
+	return verifyKey{id: id, method: method, public: public}, nil
+}
+
+// UseDenylist makes ValidateJWT reject tokens whose ID is on denylist.
+func (ks *KeySet) UseDenylist(denylist Denylist) {
+	ks.denylist = denylist
+}
+
+// Denylist returns the denylist ValidateJWT consults.
+func (ks *KeySet) Denylist() Denylist {
+	return ks.denylist
+}
+
+// ActiveKeyID returns the kid that new tokens are signed with.
+func (ks *KeySet) ActiveKeyID() string {
+	return ks.active.id
+}
+
+// lookupKey is the jwt.Keyfunc for tokens signed by this key set. The key is
+// picked by the token's kid header and has to match the token's algorithm.
+func (ks *KeySet) lookupKey(t *jwt.Token) (any, error) {
+	kid, ok := t.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errors.New("token has no key ID")
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if t.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("key %q doesn't sign with %s", kid, t.Method.Alg())
+	}
+	return key.public, nil
+}
+
+// GenerateSigningKey returns a new Ed25519 key. It's meant for development,
+// where tokens don't need to survive a restart.
+func GenerateSigningKey() (crypto.Signer, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return private, nil
+}
+
+// ParsePrivateKeyPEM reads a PKCS #8 Ed25519 or RSA private key, such as one
+// made by `openssl genpkey -algorithm ed25519`.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("expected a PRIVATE KEY block, got %s", block.Type)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+// ParsePublicKeyPEM reads the public half of a retired key. Both public key
+// and private key PEM files are accepted, so a rotated out signing key file
+// can be reused as is.
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		signer, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Public(), nil
+	default:
+		return nil, fmt.Errorf("expected a PUBLIC KEY or PRIVATE KEY block, got %s", block.Type)
+	}
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns every public key in the set, active key first, so other
+// services can verify access tokens without sharing a secret.
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ks.order {
+		key := ks.keys[id]
+		jwk := publicJWK(key.public)
+		jwk.Use = "sig"
+		jwk.Alg = key.method.Alg()
+		jwk.Kid = key.id
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func publicJWK(public crypto.PublicKey) JWK {
+	switch key := public.(type) {
+	case ed25519.PublicKey:
+		return JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(key)}
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+	default:
+		return JWK{}
+	}
+}
+
+// KeyID returns the RFC 7638 JWK thumbprint of a public key.
+func KeyID(public crypto.PublicKey) (string, error) {
+	jwk := publicJWK(public)
+
+	// The thumbprint hashes the required members in lexicographic order
+	var canonical string
+	switch jwk.Kty {
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.Kty, jwk.N)
+	default:
+		return "", fmt.Errorf("unsupported key type %T", public)
+	}
+
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
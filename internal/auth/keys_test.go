@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func newTestKeySet(t *testing.T, audience string) *KeySet {
+	t.Helper()
+	signer, err := GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() error = %v", err)
+	}
+	keys, err := NewKeySet(audience, signer)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+	return keys
+}
+
+func TestKeySetRotation(t *testing.T) {
+	userID := uuid.New()
+
+	oldSigner, _ := GenerateSigningKey()
+	oldKeys, _ := NewKeySet("chirpy", oldSigner)
+	oldToken, _ := MakeJWT(userID, oldKeys, time.Hour)
+
+	newSigner, _ := GenerateSigningKey()
+	rotated, err := NewKeySet("chirpy", newSigner, oldSigner.Public())
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	// Tokens signed before the rotation still verify
+	gotUserID, err := ValidateJWT(context.Background(), oldToken, rotated)
+	if err != nil || gotUserID != userID {
+		t.Errorf("ValidateJWT(old token) = %v, %v, want %v", gotUserID, err, userID)
+	}
+
+	// New tokens are signed with the new key only
+	newToken, _ := MakeJWT(userID, rotated, time.Hour)
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified() error = %v", err)
+	}
+	if parsed.Header["kid"] != rotated.ActiveKeyID() {
+		t.Errorf("Expected kid %q, got %v", rotated.ActiveKeyID(), parsed.Header["kid"])
+	}
+	if _, err := ValidateJWT(context.Background(), newToken, oldKeys); err == nil {
+		t.Error("Expected the old key set to reject a token signed with the new key")
+	}
+}
+
+func TestValidateJWTRejectsWrongAudience(t *testing.T) {
+	signer, _ := GenerateSigningKey()
+	issuing, _ := NewKeySet("another-service", signer)
+	verifying, _ := NewKeySet("chirpy", signer)
+
+	token, _ := MakeJWT(uuid.New(), issuing, time.Hour)
+	if _, err := ValidateJWT(context.Background(), token, verifying); err == nil {
+		t.Error("Expected a token for another audience to be rejected")
+	}
+}
+
+func TestValidateJWTRejectsHMAC(t *testing.T) {
+	keys := newTestKeySet(t, "chirpy")
+	public := keys.active.public.(ed25519.PublicKey)
+
+	// Sign with HS256 using the public key as the secret, the classic
+	// algorithm confusion attack against asymmetric JWTs
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "chirpy",
+		Subject:   uuid.New().String(),
+		Audience:  jwt.ClaimStrings{"chirpy"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	token.Header["kid"] = keys.ActiveKeyID()
+	signed, err := token.SignedString([]byte(public))
+	if err != nil {
+		t.Fatalf("SignedString() error = %v", err)
+	}
+
+	if _, err := ValidateJWT(context.Background(), signed, keys); err == nil {
+		t.Error("Expected an HS256 token to be rejected")
+	}
+}
+
+func TestRSAKeySet(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	keys, err := NewKeySet("chirpy", private)
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	userID := uuid.New()
+	token, _ := MakeJWT(userID, keys, time.Hour)
+	gotUserID, err := ValidateJWT(context.Background(), token, keys)
+	if err != nil || gotUserID != userID {
+		t.Errorf("ValidateJWT() = %v, %v, want %v", gotUserID, err, userID)
+	}
+
+	jwks := keys.JWKS()
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[0].Alg != "RS256" {
+		t.Errorf("Unexpected JWKS %+v", jwks)
+	}
+	if jwks.Keys[0].E != "AQAB" {
+		t.Errorf("Expected exponent AQAB, got %q", jwks.Keys[0].E)
+	}
+}
+
+func TestKeyID(t *testing.T) {
+	// RFC 8037 appendix A.3
+	x, _ := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	got, err := KeyID(ed25519.PublicKey(x))
+	if err != nil {
+		t.Fatalf("KeyID() error = %v", err)
+	}
+	if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; got != want {
+		t.Errorf("KeyID() = %q, want %q", got, want)
+	}
+}
+
+func TestParseKeyPEM(t *testing.T) {
+	signer, _ := GenerateSigningKey()
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	parsed, err := ParsePrivateKeyPEM(privatePEM)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM() error = %v", err)
+	}
+	if !signer.Public().(ed25519.PublicKey).Equal(parsed.Public()) {
+		t.Error("Parsed private key doesn't match the original")
+	}
+
+	// A retired signing key file can be loaded as a verify-only key
+	public, err := ParsePublicKeyPEM(privatePEM)
+	if err != nil {
+		t.Fatalf("ParsePublicKeyPEM() error = %v", err)
+	}
+	if !signer.Public().(ed25519.PublicKey).Equal(public) {
+		t.Error("Parsed public key doesn't match the original")
+	}
+
+	if _, err := ParsePrivateKeyPEM([]byte("not a key")); err == nil {
+		t.Error("Expected an error for data without a PEM block")
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import "fmt"
+
+// Role is what a user is allowed to do beyond managing their own account.
+// Roles are ranked: each one can do everything the roles below it can.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+// ParseRole returns the role named s, or an error if there's no such role.
+func ParseRole(s string) (Role, error) {
+	role := Role(s)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+	return role, nil
+}
+
+// Includes reports whether r grants everything required does.
+func (r Role) Includes(required Role) bool {
+	rank, ok := roleRanks[r]
+	if !ok {
+		return false
+	}
+	requiredRank, ok := roleRanks[required]
+	return ok && rank >= requiredRank
+}
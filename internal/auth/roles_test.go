@@ -0,0 +1,38 @@
+package auth
+
+import "testing"
+
+func TestRoleIncludes(t *testing.T) {
+	tests := []struct {
+		role     Role
+		required Role
+		want     bool
+	}{
+		{RoleAdmin, RoleAdmin, true},
+		{RoleAdmin, RoleModerator, true},
+		{RoleAdmin, RoleUser, true},
+		{RoleModerator, RoleAdmin, false},
+		{RoleModerator, RoleModerator, true},
+		{RoleUser, RoleModerator, false},
+		{Role(""), RoleUser, false},
+		{RoleAdmin, Role("owner"), false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.role.Includes(tt.required); got != tt.want {
+			t.Errorf("%q.Includes(%q) = %v, want %v", tt.role, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, name := range []string{"user", "moderator", "admin"} {
+		role, err := ParseRole(name)
+		if err != nil || string(role) != name {
+			t.Errorf("ParseRole(%q) = %q, %v", name, role, err)
+		}
+	}
+	if _, err := ParseRole("Admin"); err == nil {
+		t.Error("Expected ParseRole to be case sensitive")
+	}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPPeriod, totpDigits and SHA-1 are the parameters authenticator apps
+	// assume when an otpauth URI doesn't say otherwise.
+	TOTPPeriod = 30 * time.Second
+	totpDigits = 6
+	// totpSkew is how many periods either side of now a code is accepted
+	// for, to allow for clock drift and slow typing.
+	totpSkew         = 1
+	totpSecretBytes  = 20
+	recoveryCodeSize = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPStep returns the RFC 6238 time step t falls in.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPAt computes the RFC 6238 code for a time step: the RFC 4226 HOTP of
+// the step counter, truncated to digits decimal digits.
+func TOTPAt(secret []byte, step int64, digits int, newHash func() hash.Hash) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(newHash, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	binaryCode := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for range digits {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, binaryCode%modulo)
+}
+
+// GenerateTOTPSecret returns a new random secret, base32 encoded the way
+// authenticator apps expect.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, totpSecretBytes)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(secret), nil
+}
+
+// ValidateTOTP checks a 6 digit code against a base32 secret and returns the
+// time step it matched. Callers should only accept a step newer than the
+// last one used, so a code can't be replayed.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected := TOTPAt(key, step, totpDigits, sha1.New)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI returns the otpauth:// URI authenticator apps read,
+// usually from a QR code.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// GenerateRecoveryCode returns a single-use code for when the authenticator
+// isn't available, formatted as four groups of four characters.
+func GenerateRecoveryCode() (string, error) {
+	raw := make([]byte, recoveryCodeSize)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(base32NoPadding.EncodeToString(raw))
+	return encoded[0:4] + "-" + encoded[4:8] + "-" + encoded[8:12] + "-" + encoded[12:16], nil
+}
+
+// HashRecoveryCode returns the hex encoded SHA-256 digest of a recovery
+// code, ignoring case, spaces and dashes. Codes carry 80 random bits, so like
+// refresh tokens they don't need a slow password hash.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B
+func TestTOTPAtRFCVectors(t *testing.T) {
+	seeds := map[string]struct {
+		secret  []byte
+		newHash func() hash.Hash
+	}{
+		"SHA1":   {[]byte("12345678901234567890"), sha1.New},
+		"SHA256": {[]byte("12345678901234567890123456789012"), sha256.New},
+		"SHA512": {[]byte("1234567890123456789012345678901234567890123456789012345678901234"), sha512.New},
+	}
+
+	tests := []struct {
+		unixTime int64
+		mode     string
+		want     string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+time.Unix(tt.unixTime, 0).UTC().Format(time.RFC3339), func(t *testing.T) {
+			seed := seeds[tt.mode]
+			step := TOTPStep(time.Unix(tt.unixTime, 0))
+			if got := TOTPAt(seed.secret, step, 8, seed.newHash); got != tt.want {
+				t.Errorf("TOTPAt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	// Setup
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	key, _ := base32NoPadding.DecodeString(secret)
+	now := time.Unix(1700000000, 0)
+	code := TOTPAt(key, TOTPStep(now), totpDigits, sha1.New)
+
+	// Assertions
+	step, ok := ValidateTOTP(secret, code, now)
+	if !ok || step != TOTPStep(now) {
+		t.Errorf("ValidateTOTP() = %d, %v, want %d, true", step, ok, TOTPStep(now))
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(TOTPPeriod)); !ok {
+		t.Error("Expected a code from the previous period to be accepted")
+	}
+	if _, ok := ValidateTOTP(secret, code, now.Add(3*TOTPPeriod)); ok {
+		t.Error("Expected a code from three periods ago to be rejected")
+	}
+	if _, ok := ValidateTOTP(secret, "12345", now); ok {
+		t.Error("Expected a code with the wrong length to be rejected")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Chirpy", "walt@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+	if parsed.Scheme != "otpauth" || parsed.Host != "totp" {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+	if parsed.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("Unexpected label %q", parsed.Path)
+	}
+	if parsed.Query().Get("secret") != "JBSWY3DPEHPK3PXP" || parsed.Query().Get("issuer") != "Chirpy" {
+		t.Errorf("Unexpected query %q", parsed.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode() error = %v", err)
+	}
+	if len(code) != 19 || strings.Count(code, "-") != 3 {
+		t.Errorf("Unexpected recovery code format %q", code)
+	}
+
+	// Codes typed without dashes or in upper case still match
+	typed := strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+	if HashRecoveryCode(typed) != HashRecoveryCode(code) {
+		t.Error("Expected the hash to ignore case and dashes")
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// emailVerificationAudience is added to the key set's audience for email
+// verification tokens, so they can never pass as access tokens.
+const emailVerificationAudience = ":verify-email"
+
+type emailVerificationClaims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+// MakeEmailVerificationToken signs a token proving that whoever holds it can
+// read mail sent to email. It goes in the link sent to that address.
+func MakeEmailVerificationToken(userID uuid.UUID, email string, keys *KeySet, expiresIn time.Duration) (string, error) {
+	claims := emailVerificationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer,
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{keys.audience + emailVerificationAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+		},
+		Email: email,
+	}
+
+	token := jwt.NewWithClaims(keys.active.method, claims)
+	token.Header["kid"] = keys.active.id
+	return token.SignedString(keys.signer)
+}
+
+// ValidateEmailVerificationToken returns the user and the email address a
+// token from MakeEmailVerificationToken was issued for.
+func ValidateEmailVerificationToken(tokenString string, keys *KeySet) (uuid.UUID, string, error) {
+	claims := emailVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, &claims, keys.lookupKey,
+		jwt.WithValidMethods(allowedAlgorithms),
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(keys.audience+emailVerificationAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if claims.Email == "" {
+		return uuid.Nil, "", errors.New("token has no email address")
+	}
+	return userID, claims.Email, nil
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestEmailVerificationToken(t *testing.T) {
+	// Setup
+	userID := uuid.New()
+	keys := newTestKeySet(t, "chirpy")
+
+	// Execute
+	token, err := MakeEmailVerificationToken(userID, "walt@example.com", keys, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken() error = %v", err)
+	}
+	gotUserID, gotEmail, err := ValidateEmailVerificationToken(token, keys)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("ValidateEmailVerificationToken() error = %v", err)
+	}
+	if gotUserID != userID || gotEmail != "walt@example.com" {
+		t.Errorf("ValidateEmailVerificationToken() = %v, %q, want %v, %q", gotUserID, gotEmail, userID, "walt@example.com")
+	}
+}
+
+func TestEmailVerificationTokensAreNotAccessTokens(t *testing.T) {
+	userID := uuid.New()
+	keys := newTestKeySet(t, "chirpy")
+
+	verificationToken, _ := MakeEmailVerificationToken(userID, "walt@example.com", keys, time.Hour)
+	if _, err := ValidateJWT(context.Background(), verificationToken, keys); err == nil {
+		t.Error("Expected a verification token to be rejected as an access token")
+	}
+
+	accessToken, _ := MakeJWT(userID, keys, time.Hour)
+	if _, _, err := ValidateEmailVerificationToken(accessToken, keys); err == nil {
+		t.Error("Expected an access token to be rejected as a verification token")
+	}
+
+	expired, _ := MakeEmailVerificationToken(userID, "walt@example.com", keys, -time.Minute)
+	if _, _, err := ValidateEmailVerificationToken(expired, keys); err == nil {
+		t.Error("Expected an expired verification token to be rejected")
+	}
+}
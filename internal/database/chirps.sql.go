@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	InReplyTo uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.InReplyTo)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +41,10 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.LikeCount,
+		&i.RechirpCount,
 	)
 	return i, err
 }
@@ -51,8 +59,174 @@ func (q *Queries) DeleteChirpsByID(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.created_at, parent.updated_at, parent.body, parent.user_id, parent.in_reply_to, parent.like_count, parent.rechirp_count, 1 AS depth
+    FROM chirps parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps child WHERE child.id = $1)
+    UNION ALL
+    SELECT parent.id, parent.created_at, parent.updated_at, parent.body, parent.user_id, parent.in_reply_to, parent.like_count, parent.rechirp_count, ancestors.depth + 1
+    FROM chirps parent
+    INNER JOIN ancestors
+    ON parent.id = ancestors.in_reply_to
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count, depth
+FROM ancestors
+ORDER BY depth DESC
+`
+
+type GetChirpAncestorsRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	LikeCount    int32
+	RechirpCount int32
+	Depth        int32
+}
+
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]GetChirpAncestorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpAncestorsRow
+	for rows.Next() {
+		var i GetChirpAncestorsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpDescendants = `-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT child.id, child.created_at, child.updated_at, child.body, child.user_id, child.in_reply_to, child.like_count, child.rechirp_count, 1 AS depth
+    FROM chirps child
+    WHERE child.in_reply_to = $1
+    UNION ALL
+    SELECT child.id, child.created_at, child.updated_at, child.body, child.user_id, child.in_reply_to, child.like_count, child.rechirp_count, descendants.depth + 1
+    FROM chirps child
+    INNER JOIN descendants
+    ON child.in_reply_to = descendants.id
+    WHERE descendants.depth < $2::int
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count, depth
+FROM descendants
+ORDER BY depth ASC, created_at ASC, id ASC
+`
+
+type GetChirpDescendantsParams struct {
+	RootID   uuid.UUID
+	MaxDepth int32
+}
+
+type GetChirpDescendantsRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	LikeCount    int32
+	RechirpCount int32
+	Depth        int32
+}
+
+func (q *Queries) GetChirpDescendants(ctx context.Context, arg GetChirpDescendantsParams) ([]GetChirpDescendantsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpDescendants, arg.RootID, arg.MaxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChirpDescendantsRow
+	for rows.Next() {
+		var i GetChirpDescendantsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Depth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpForEdit = `-- name: GetChirpForEdit :one
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count,
+    chirps.created_at > NOW() - make_interval(secs => $2::float8) AS editable
+FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+type GetChirpForEditParams struct {
+	ID                uuid.UUID
+	EditWindowSeconds float64
+}
+
+type GetChirpForEditRow struct {
+	Chirp    Chirp
+	Editable bool
+}
+
+// Editable is worked out here rather than in Go, since created_at has no
+// time zone and only means something next to the database's own NOW().
+func (q *Queries) GetChirpForEdit(ctx context.Context, arg GetChirpForEditParams) (GetChirpForEditRow, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForEdit, arg.ID, arg.EditWindowSeconds)
+	var i GetChirpForEditRow
+	err := row.Scan(
+		&i.Chirp.ID,
+		&i.Chirp.CreatedAt,
+		&i.Chirp.UpdatedAt,
+		&i.Chirp.Body,
+		&i.Chirp.UserID,
+		&i.Chirp.SearchVector,
+		&i.Chirp.InReplyTo,
+		&i.Chirp.LikeCount,
+		&i.Chirp.RechirpCount,
+		&i.Editable,
+	)
+	return i, err
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
 FROM chirps
 ORDER BY created_at ASC
 `
@@ -72,6 +246,10 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
 		); err != nil {
 			return nil, err
 		}
@@ -87,7 +265,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByID = `-- name: GetChirpsByID :one
-SELECT id, created_at, updated_at, body, user_id
+SELECT id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
 FROM chirps
 WHERE $1 = id
 `
@@ -101,6 +279,615 @@ func (q *Queries) GetChirpsByID(ctx context.Context, id uuid.UUID) (Chirp, error
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.LikeCount,
+		&i.RechirpCount,
+	)
+	return i, err
+}
+
+const listChirpsAsc = `-- name: ListChirpsAsc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
+FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT $4
+`
+
+type ListChirpsAscParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListChirpsAsc(ctx context.Context, arg ListChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsAsc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
+FROM chirps
+WHERE ($1::uuid IS NULL OR user_id = $1::uuid)
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMentionChirpsAsc = `-- name: ListMentionChirpsAsc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN chirp_mentions
+ON chirps.id = chirp_mentions.chirp_id
+WHERE chirp_mentions.user_id = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListMentionChirpsAscParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListMentionChirpsAsc(ctx context.Context, arg ListMentionChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listMentionChirpsAsc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMentionChirpsDesc = `-- name: ListMentionChirpsDesc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN chirp_mentions
+ON chirps.id = chirp_mentions.chirp_id
+WHERE chirp_mentions.user_id = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListMentionChirpsDescParams struct {
+	UserID          uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListMentionChirpsDesc(ctx context.Context, arg ListMentionChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listMentionChirpsDesc,
+		arg.UserID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagChirpsAsc = `-- name: ListTagChirpsAsc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN chirp_hashtags
+ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.tag = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListTagChirpsAscParams struct {
+	Tag             string
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListTagChirpsAsc(ctx context.Context, arg ListTagChirpsAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTagChirpsAsc,
+		arg.Tag,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTagChirpsDesc = `-- name: ListTagChirpsDesc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN chirp_hashtags
+ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.tag = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListTagChirpsDescParams struct {
+	Tag             string
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListTagChirpsDesc(ctx context.Context, arg ListTagChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTagChirpsDesc,
+		arg.Tag,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineAsc = `-- name: ListTimelineAsc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN follows
+ON chirps.user_id = follows.followee_id
+WHERE follows.follower_id = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT $4
+`
+
+type ListTimelineAscParams struct {
+	FollowerID      uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListTimelineAsc(ctx context.Context, arg ListTimelineAscParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineAsc,
+		arg.FollowerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelineDesc = `-- name: ListTimelineDesc :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.in_reply_to, chirps.like_count, chirps.rechirp_count
+FROM chirps
+INNER JOIN follows
+ON chirps.user_id = follows.followee_id
+WHERE follows.follower_id = $1
+AND (
+    $2::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT $4
+`
+
+type ListTimelineDescParams struct {
+	FollowerID      uuid.UUID
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	RowLimit        int32
+}
+
+func (q *Queries) ListTimelineDesc(ctx context.Context, arg ListTimelineDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelineDesc,
+		arg.FollowerID,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.RowLimit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUserChirps = `-- name: ListUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) ListUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listUserChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count,
+    ts_rank(search_vector, websearch_to_tsquery('english', $1)) AS rank
+FROM chirps
+WHERE search_vector @@ websearch_to_tsquery('english', $1)
+AND ($2::uuid IS NULL OR user_id = $2::uuid)
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $3
+OFFSET $4
+`
+
+type SearchChirpsParams struct {
+	Query     string
+	AuthorID  uuid.NullUUID
+	RowLimit  int32
+	RowOffset int32
+}
+
+type SearchChirpsRow struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	InReplyTo    uuid.NullUUID
+	LikeCount    int32
+	RechirpCount int32
+	Rank         float32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.RowLimit,
+		arg.RowOffset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.LikeCount,
+			&i.RechirpCount,
+			&i.Rank,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, search_vector, in_reply_to, like_count, rechirp_count
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID
+	Body string
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.InReplyTo,
+		&i.LikeCount,
+		&i.RechirpCount,
 	)
 	return i, err
 }
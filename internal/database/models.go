@@ -11,28 +11,156 @@ import (
 	"github.com/google/uuid"
 )
 
+type AccountDeletion struct {
+	UserID      uuid.UUID
+	RequestedAt time.Time
+	PurgeAfter  time.Time
+}
+
 type Chirp struct {
-	ID        uuid.UUID
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Body         string
+	UserID       uuid.UUID
+	SearchVector string
+	InReplyTo    uuid.NullUUID
+	LikeCount    int32
+	RechirpCount int32
+}
+
+type ChirpEvent struct {
+	ID        int64
+	EventType string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID
+	Term      string
+	FlaggedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID uuid.UUID
+	Tag     string
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type ChirpRevision struct {
+	ID        uuid.UUID
+	ChirpID   uuid.UUID
 	Body      string
+	CreatedAt time.Time
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type LoginChallenge struct {
+	TokenHash string
 	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int32
 }
 
-type RefreshToken struct {
-	Token     string
+type Medium struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ContentType  string
+	StorageKey   string
+	ThumbnailKey string
+	Width        int32
+	Height       int32
+	ChirpID      uuid.NullUUID
+	Position     sql.NullInt32
+}
+
+type ModerationRule struct {
+	Term      string
+	Action    string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+}
+
+type PasswordResetToken struct {
+	TokenHash string
 	UserID    uuid.UUID
+	CreatedAt time.Time
 	ExpiresAt time.Time
-	RevokedAt sql.NullTime
+	UsedAt    sql.NullTime
 }
 
-type User struct {
-	ID             uuid.UUID
+type Rechirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID
+	CodeHash  string
+	CreatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type RefreshToken struct {
+	Token      string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	ReplacedBy sql.NullString
+	UserAgent  string
+	IpAddress  string
+	LastUsedAt time.Time
+}
+
+type RevokedAccessToken struct {
+	Jti       string
+	ExpiresAt time.Time
+}
+
+type TotpCredential struct {
+	UserID         uuid.UUID
+	Secret         string
 	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ConfirmedAt    sql.NullTime
+	LastUsedStep   int64
+	FailedAttempts int32
+	LockedUntil    sql.NullTime
+}
+
+type User struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  string
+	IsChirpyRed     bool
+	Username        sql.NullString
+	EmailVerifiedAt sql.NullTime
+	PendingEmail    sql.NullString
+	DisplayName     string
+	Bio             string
+	Role            string
 }
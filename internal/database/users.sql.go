@@ -7,29 +7,32 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Username       sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Username)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -38,12 +41,44 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
+FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
 	)
 	return i, err
 }
 
 const getUserUsingEmail = `-- name: GetUserUsingEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
 FROM users
 WHERE $1 = email
 `
@@ -58,10 +93,30 @@ func (q *Queries) GetUserUsingEmail(ctx context.Context, email string) (User, er
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
 	)
 	return i, err
 }
 
+const purgeUser = `-- name: PurgeUser :execrows
+DELETE FROM users
+WHERE id = $1
+AND id IN (SELECT user_id FROM account_deletions WHERE purge_after <= NOW())
+`
+
+func (q *Queries) PurgeUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const resetUsers = `-- name: ResetUsers :exec
 TRUNCATE TABLE users CASCADE
 `
@@ -71,21 +126,59 @@ func (q *Queries) ResetUsers(ctx context.Context) error {
 	return err
 }
 
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
+`
+
+type SetUserRoleParams struct {
+	ID   uuid.UUID
+	Role string
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.ID, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
+	)
+	return i, err
+}
+
 const updateUserPassEmail = `-- name: UpdateUserPassEmail :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET hashed_password = $2, pending_email = $3, username = COALESCE($4, username), updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
 `
 
 type UpdateUserPassEmailParams struct {
 	ID             uuid.UUID
-	Email          string
 	HashedPassword string
+	PendingEmail   sql.NullString
+	Username       sql.NullString
 }
 
 func (q *Queries) UpdateUserPassEmail(ctx context.Context, arg UpdateUserPassEmailParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, updateUserPassEmail, arg.ID, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, updateUserPassEmail,
+		arg.ID,
+		arg.HashedPassword,
+		arg.PendingEmail,
+		arg.Username,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -94,6 +187,78 @@ func (q *Queries) UpdateUserPassEmail(ctx context.Context, arg UpdateUserPassEma
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword string
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
+const updateUserProfile = `-- name: UpdateUserProfile :one
+UPDATE users
+SET hashed_password = COALESCE($1, hashed_password),
+    pending_email = CASE WHEN $2::bool THEN $3 ELSE pending_email END,
+    username = COALESCE($4, username),
+    display_name = COALESCE($5, display_name),
+    bio = COALESCE($6, bio),
+    updated_at = NOW()
+WHERE id = $7
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
+`
+
+type UpdateUserProfileParams struct {
+	HashedPassword     sql.NullString
+	ChangePendingEmail bool
+	PendingEmail       sql.NullString
+	Username           sql.NullString
+	DisplayName        sql.NullString
+	Bio                sql.NullString
+	ID                 uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.HashedPassword,
+		arg.ChangePendingEmail,
+		arg.PendingEmail,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
 	)
 	return i, err
 }
@@ -102,7 +267,7 @@ const upgradeToChirpyRed = `-- name: UpgradeToChirpyRed :one
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
 `
 
 func (q *Queries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) (User, error) {
@@ -115,6 +280,47 @@ func (q *Queries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET email = $1,
+    pending_email = CASE WHEN pending_email = $1 THEN NULL ELSE pending_email END,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = $2 AND (email = $1 OR pending_email = $1)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, username, email_verified_at, pending_email, display_name, bio, role
+`
+
+type VerifyUserEmailParams struct {
+	Email string
+	ID    uuid.UUID
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Email, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.EmailVerifiedAt,
+		&i.PendingEmail,
+		&i.DisplayName,
+		&i.Bio,
+		&i.Role,
 	)
 	return i, err
 }
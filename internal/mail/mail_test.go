@@ -0,0 +1,131 @@
+package mail
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	msg := Message{To: "walt@example.com", Subject: "Reset your password", Body: "Line one\nLine two"}
+	data, err := render("chirpy@example.com", msg, time.Unix(0, 0).UTC())
+	if err != nil {
+		t.Fatalf("render() error = %v", err)
+	}
+
+	got := string(data)
+	for _, want := range []string{
+		"From: chirpy@example.com\r\n",
+		"To: walt@example.com\r\n",
+		"Subject: Reset your password\r\n",
+		"\r\n\r\nLine one\r\nLine two",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected the message to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestRenderRejectsHeaderInjection(t *testing.T) {
+	msg := Message{To: "walt@example.com\r\nBcc: everyone@example.com", Subject: "Hi"}
+	if _, err := render("chirpy@example.com", msg, time.Now()); err == nil {
+		t.Error("Expected a recipient with a line break to be rejected")
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	// Setup
+	dir := t.TempDir()
+	mailer, err := NewFileMailer(dir, "chirpy@example.com")
+	if err != nil {
+		t.Fatalf("NewFileMailer() error = %v", err)
+	}
+
+	// Execute
+	err = mailer.Send(context.Background(), Message{To: "walt@example.com", Subject: "Hi", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// Assertions
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("Expected 1 message file, got %d", len(files))
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "To: walt@example.com") {
+		t.Errorf("Unexpected message %q", data)
+	}
+}
+
+func TestSMTPMailerEnvelopeSender(t *testing.T) {
+	// Setup: a server that answers just enough SMTP to accept one message
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	commands := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ready")
+
+		var seen []string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				break
+			}
+			seen = append(seen, line)
+			switch verb, _, _ := strings.Cut(line, " "); strings.ToUpper(verb) {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				text.ReadDotLines()
+				text.PrintfLine("250 queued")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				commands <- seen
+				return
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+		commands <- seen
+	}()
+
+	mailer, err := NewSMTPMailer(listener.Addr().String(), "Chirpy <no-reply@localhost>", "", "")
+	if err != nil {
+		t.Fatalf("NewSMTPMailer() error = %v", err)
+	}
+
+	// Execute
+	err = mailer.Send(context.Background(), Message{To: "walt@example.com", Subject: "Hi", Body: "Hello"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	// Assertions: the envelope takes the bare address, not the display form
+	seen := <-commands
+	if !slices.Contains(seen, "MAIL FROM:<no-reply@localhost>") {
+		t.Errorf("Expected MAIL FROM:<no-reply@localhost>, got %q", seen)
+	}
+
+	_, err = NewSMTPMailer("localhost:25", "Chirpy no-reply", "", "")
+	if err == nil {
+		t.Error("Expected a sender without an address to be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	MaxUploadBytes = 5 << 20
+	// maxPixels bounds the memory a decoded image takes: 16 MP is 64 MB as
+	// 8-bit RGBA and 128 MB at 16 bits per channel, whatever the file size.
+	maxPixels     = 16_000_000
+	thumbnailSize = 320
+	jpegQuality   = 85
+	// maxConcurrentProcessing is how many uploads are decoded at once.
+	// Others wait their turn, so memory use stays bounded under load.
+	maxConcurrentProcessing = 2
+)
+
+var processing = make(chan struct{}, maxConcurrentProcessing)
+
+var (
+	ErrUnsupportedType = errors.New("only JPEG and PNG images are supported")
+	ErrTooLarge        = errors.New("image is too large")
+)
+
+// Image is an upload that has been decoded and re-encoded. Re-encoding drops
+// every metadata segment, which is how EXIF data (including GPS location)
+// gets stripped.
+type Image struct {
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+	Data        []byte
+	Thumbnail   []byte
+}
+
+// Process validates an uploaded image, strips its metadata and produces a
+// thumbnail no larger than thumbnailSize on either side. A JPEG's EXIF
+// Orientation is applied before the metadata goes, so photos stay upright.
+// It waits while maxConcurrentProcessing other images are being processed,
+// returning early if ctx is cancelled.
+func Process(ctx context.Context, data []byte) (Image, error) {
+	if len(data) > MaxUploadBytes {
+		return Image{}, ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	// Check the dimensions before decoding so a small file that claims to
+	// be enormous can't exhaust memory.
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return Image{}, ErrTooLarge
+	}
+
+	select {
+	case processing <- struct{}{}:
+		defer func() { <-processing }()
+	case <-ctx.Done():
+		return Image{}, ctx.Err()
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, err
+	}
+	if contentType == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	full, err := encode(img, contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	thumb, err := encode(Thumbnail(img, thumbnailSize), contentType)
+	if err != nil {
+		return Image{}, err
+	}
+
+	extension := ".jpg"
+	if contentType == "image/png" {
+		extension = ".png"
+	}
+
+	return Image{
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Data:        full,
+		Thumbnail:   thumb,
+	}, nil
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	buf := bytes.Buffer{}
+	var err error
+	if contentType == "image/png" {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
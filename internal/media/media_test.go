@@ -0,0 +1,154 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	buf := bytes.Buffer{}
+	err := jpeg.Encode(&buf, img, nil)
+	if err != nil {
+		t.Fatalf("encoding test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF inserts an APP1 Exif segment right after the JPEG SOI marker.
+func withEXIF(data []byte) []byte {
+	payload := []byte("Exif\x00\x00secret-gps-location")
+	length := len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessStripsEXIF(t *testing.T) {
+	upload := withEXIF(testJPEG(t, 64, 48))
+	if !bytes.Contains(upload, []byte("secret-gps-location")) {
+		t.Fatal("test upload is missing its EXIF segment")
+	}
+
+	img, err := Process(context.Background(), upload)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("Exif")) || bytes.Contains(img.Data, []byte("secret-gps-location")) {
+		t.Error("processed image still contains EXIF data")
+	}
+	if img.ContentType != "image/jpeg" || img.Width != 64 || img.Height != 48 {
+		t.Errorf("Process() = %s %dx%d, want image/jpeg 64x48", img.ContentType, img.Width, img.Height)
+	}
+}
+
+// withOrientation inserts an APP1 Exif segment holding just an Orientation
+// tag right after the JPEG SOI marker.
+func withOrientation(data []byte, orientation uint16) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // big endian header, IFD0 at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, byte(orientation >> 8), byte(orientation), 0, 0, // Orientation, SHORT
+		0, 0, 0, 0, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+	segment := append([]byte{0xFF, 0xE1, byte(length >> 8), byte(length)}, payload...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	// Left half red, right half blue
+	src := image.NewRGBA(image.Rect(0, 0, 64, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= 32 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			src.Set(x, y, c)
+		}
+	}
+	buf := bytes.Buffer{}
+	err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatalf("encoding test image: %v", err)
+	}
+
+	// 6 means the camera was turned clockwise, so the stored image has to be
+	// rotated 90° clockwise to be upright
+	img, err := Process(context.Background(), withOrientation(buf.Bytes(), 6))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if img.Width != 32 || img.Height != 64 {
+		t.Fatalf("Process() = %dx%d, want 32x64", img.Width, img.Height)
+	}
+
+	decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		t.Fatalf("decoding processed image: %v", err)
+	}
+	if r, _, b, _ := decoded.At(16, 8).RGBA(); r < b {
+		t.Error("Expected the left side of the stored image to end up on top")
+	}
+	if r, _, b, _ := decoded.At(16, 56).RGBA(); b < r {
+		t.Error("Expected the right side of the stored image to end up at the bottom")
+	}
+}
+
+func TestJPEGOrientationIgnoresGarbage(t *testing.T) {
+	if got := jpegOrientation(withEXIF(testJPEG(t, 8, 8))); got != 1 {
+		t.Errorf("jpegOrientation() = %d for unreadable EXIF data, want 1", got)
+	}
+	if got := jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF}); got != 1 {
+		t.Errorf("jpegOrientation() = %d for a truncated file, want 1", got)
+	}
+}
+
+func TestProcessRejectsNonImages(t *testing.T) {
+	_, err := Process(context.Background(), []byte("<html><body>not an image</body></html>"))
+	if err != ErrUnsupportedType {
+		t.Errorf("Process() error = %v, want %v", err, ErrUnsupportedType)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	tests := []struct {
+		name       string
+		width      int
+		height     int
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "landscape", width: 1000, height: 500, wantWidth: 320, wantHeight: 160},
+		{name: "portrait", width: 400, height: 800, wantWidth: 160, wantHeight: 320},
+		{name: "already small", width: 100, height: 50, wantWidth: 100, wantHeight: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+			thumb := Thumbnail(img, thumbnailSize)
+			got := thumb.Bounds()
+			if got.Dx() != tt.wantWidth || got.Dy() != tt.wantHeight {
+				t.Errorf("Thumbnail() = %dx%d, want %dx%d", got.Dx(), got.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// Package moderation checks user written text against word lists. Each rule
+// decides whether a matching word is masked, gets the text rejected or flags
+// it for a moderator to review.
+package moderation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mask replaces every masked word. Words shorter than Mask are replaced by as
+// many of its characters as they have, so masking never makes text longer.
+const Mask = "****"
+
+// Action is what happens to text containing a rule's term.
+type Action string
+
+const (
+	ActionMask   Action = "mask"
+	ActionReject Action = "reject"
+	ActionFlag   Action = "flag"
+)
+
+// ParseAction returns the action named s, or an error if there's no such
+// action.
+func ParseAction(s string) (Action, error) {
+	switch action := Action(s); action {
+	case ActionMask, ActionReject, ActionFlag:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown moderation action %q", s)
+	}
+}
+
+// Rule applies Action to every word that normalizes to the same form as
+// Term.
+type Rule struct {
+	Term   string
+	Action Action
+}
+
+// Match is a word that matched a rule. Word is the text as it was written.
+type Match struct {
+	Rule Rule
+	Word string
+}
+
+// Result is the outcome of checking some text. Text has the masked words
+// replaced, and Matches lists every word that matched a rule, in order.
+type Result struct {
+	Text    string
+	Matches []Match
+}
+
+// Rejected reports whether the text matched a reject rule and must not be
+// posted.
+func (r Result) Rejected() bool {
+	return r.has(ActionReject)
+}
+
+// Flagged reports whether the text matched a flag rule and should be
+// reviewed.
+func (r Result) Flagged() bool {
+	return r.has(ActionFlag)
+}
+
+func (r Result) has(action Action) bool {
+	for _, match := range r.Matches {
+		if match.Rule.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter checks text against a set of rules.
+type Filter interface {
+	Check(text string) Result
+}
+
+// Pipeline runs filters in order, each on the text left by the one before,
+// and collects all of their matches.
+type Pipeline []Filter
+
+func (p Pipeline) Check(text string) Result {
+	result := Result{Text: text}
+	for _, filter := range p {
+		next := filter.Check(result.Text)
+		result.Text = next.Text
+		result.Matches = append(result.Matches, next.Matches...)
+	}
+	return result
+}
+
+// WordFilter matches whole words, ignoring case, surrounding punctuation and
+// leetspeak, so "Sharbert!", "SHARBERT" and "5h4rb3r7" all match the term
+// "sharbert". Words only match whole terms: "fornax" doesn't match
+// "fornaxes". Leetspeak symbols between letters split a word when it
+// doesn't match as a whole, so "fornax!sharbert" matches both terms.
+type WordFilter struct {
+	// terms groups rules by the skeleton of their normalized term, which is
+	// where lookups for a word start.
+	terms map[string][]wordRule
+}
+
+type wordRule struct {
+	term string
+	rule Rule
+}
+
+// NewWordFilter builds a filter from rules. When two rules have terms that
+// normalize to the same word, the later one wins, so rules can be layered
+// by appending overrides.
+func NewWordFilter(rules []Rule) (*WordFilter, error) {
+	f := &WordFilter{terms: make(map[string][]wordRule, len(rules))}
+	for _, rule := range rules {
+		_, err := ParseAction(string(rule.Action))
+		if err != nil {
+			return nil, err
+		}
+		term, err := NormalizeTerm(rule.Term)
+		if err != nil {
+			return nil, err
+		}
+
+		key := skeleton(term)
+		candidates := f.terms[key]
+		i := slices.IndexFunc(candidates, func(c wordRule) bool { return c.term == term })
+		if i >= 0 {
+			candidates[i].rule = rule
+			continue
+		}
+		f.terms[key] = append(candidates, wordRule{term: term, rule: rule})
+	}
+	return f, nil
+}
+
+func (f *WordFilter) Check(text string) Result {
+	result := Result{}
+	var out strings.Builder
+	last := 0
+	for start, end := range words(text) {
+		for _, match := range f.match(text[start:end]) {
+			matchStart, matchEnd := start+match.start, start+match.end
+			result.Matches = append(result.Matches, Match{Rule: match.rule, Word: text[matchStart:matchEnd]})
+			if match.rule.Action == ActionMask {
+				out.WriteString(text[last:matchStart])
+				out.WriteString(mask(text[matchStart:matchEnd]))
+				last = matchEnd
+			}
+		}
+	}
+	out.WriteString(text[last:])
+	result.Text = out.String()
+	return result
+}
+
+// mask returns what word is replaced with: Mask, cut to word's length when
+// word is shorter.
+func mask(word string) string {
+	return Mask[:min(len(Mask), utf8.RuneCountInString(word))]
+}
+
+// span is the part of a word that matched a rule, as byte offsets.
+type span struct {
+	rule       Rule
+	start, end int
+}
+
+// match finds the rules word matches. It looks word up as written, then
+// with the symbols around it trimmed off, then each part between the
+// symbols inside it. That way "$harbert" matches through leetspeak, the "!"
+// in "Sharbert!" is treated as punctuation, and "fornax@sharbert" can't
+// hide two terms in one word.
+func (f *WordFilter) match(word string) []span {
+	if rule, ok := f.lookup(word); ok {
+		return []span{{rule: rule, start: 0, end: len(word)}}
+	}
+	start := len(word) - len(strings.TrimLeftFunc(word, isLeetSymbol))
+	end := len(strings.TrimRightFunc(word, isLeetSymbol))
+	if start >= end {
+		return nil
+	}
+	if start > 0 || end < len(word) {
+		if rule, ok := f.lookup(word[start:end]); ok {
+			return []span{{rule: rule, start: start, end: end}}
+		}
+	}
+
+	var spans []span
+	partStart := start
+	checkPart := func(partEnd int) {
+		if partStart >= partEnd || (partStart == start && partEnd == end) {
+			return
+		}
+		if rule, ok := f.lookup(word[partStart:partEnd]); ok {
+			spans = append(spans, span{rule: rule, start: partStart, end: partEnd})
+		}
+	}
+	for i, r := range word[start:end] {
+		if isLeetSymbol(r) {
+			checkPart(start + i)
+			partStart = start + i + utf8.RuneLen(r)
+		}
+	}
+	checkPart(end)
+	return spans
+}
+
+// lookup finds the rule for word. Words are grouped by skeleton first, then
+// compared rune by rune, so a "1" can stand for "i" or "l" while "i" and "l"
+// still only match themselves: a rule for "hell" matches "he11" but not
+// "heil".
+func (f *WordFilter) lookup(word string) (Rule, bool) {
+	normalized := normalize(word)
+	for _, candidate := range f.terms[skeleton(normalized)] {
+		if sameWord(normalized, candidate.term) {
+			return candidate.rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+// ambiguousIL is what normalize turns the symbols written for both "i" and
+// "l" into.
+const ambiguousIL = '1'
+
+// leet maps the symbols and digits commonly written in place of letters.
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': ambiguousIL,
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+	'@': 'a',
+	'$': 's',
+	'!': ambiguousIL,
+	'|': ambiguousIL,
+	'+': 't',
+}
+
+// isWordRune reports whether r can be part of a word. Leetspeak symbols
+// count, as do combining marks and invisible format characters, so they
+// can't be used to split a word in two.
+func isWordRune(r rune) bool {
+	_, isLeet := leet[r]
+	return isLeet || unicode.IsLetter(r) || unicode.IsDigit(r) ||
+		unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r)
+}
+
+// isLeetSymbol reports whether r is a leetspeak symbol that is also used as
+// punctuation, like "!" or "@".
+func isLeetSymbol(r rune) bool {
+	_, isLeet := leet[r]
+	return isLeet && !unicode.IsDigit(r)
+}
+
+// words yields the byte offsets of each run of word runes in text.
+func words(text string) func(yield func(int, int) bool) {
+	return func(yield func(int, int) bool) {
+		start := -1
+		for i, r := range text {
+			if isWordRune(r) {
+				if start < 0 {
+					start = i
+				}
+				continue
+			}
+			if start >= 0 && !yield(start, i) {
+				return
+			}
+			start = -1
+		}
+		if start >= 0 {
+			yield(start, len(text))
+		}
+	}
+}
+
+// normalize folds word into the form rules are matched in: case folded,
+// leetspeak replaced by letters, and combining marks and invisible
+// characters dropped. Symbols that could be either "i" or "l" become
+// ambiguousIL.
+func normalize(word string) string {
+	var b strings.Builder
+	b.Grow(len(word))
+	for _, r := range word {
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		if letter, ok := leet[r]; ok {
+			r = letter
+		}
+		b.WriteRune(unicode.ToLower(unicode.ToUpper(r)))
+	}
+	return b.String()
+}
+
+// skeleton merges "i", "l" and ambiguousIL in a normalized word, so every
+// word that could match a term has the same skeleton as it.
+func skeleton(normalized string) string {
+	return strings.Map(func(r rune) rune {
+		if r == 'l' || r == ambiguousIL {
+			return 'i'
+		}
+		return r
+	}, normalized)
+}
+
+// sameWord reports whether the normalized word matches the normalized term:
+// every rune is the same, except that ambiguousIL in the word matches "i"
+// or "l" in the term.
+func sameWord(word, term string) bool {
+	termRunes := []rune(term)
+	i := 0
+	for _, r := range word {
+		if i >= len(termRunes) {
+			return false
+		}
+		t := termRunes[i]
+		if r != t && !(r == ambiguousIL && (t == 'i' || t == 'l')) {
+			return false
+		}
+		i++
+	}
+	return i == len(termRunes)
+}
+
+// NormalizeTerm returns the form term is matched in, or an error if term
+// isn't a single word.
+func NormalizeTerm(term string) (string, error) {
+	term = strings.TrimSpace(term)
+	if term == "" || !utf8.ValidString(term) {
+		return "", fmt.Errorf("moderation term %q must be a word", term)
+	}
+	for _, r := range term {
+		if !isWordRune(r) {
+			return "", fmt.Errorf("moderation term %q must be a single word", term)
+		}
+	}
+	return normalize(term), nil
+}
@@ -0,0 +1,169 @@
+package moderation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWordFilterCheck(t *testing.T) {
+	filter, err := NewWordFilter(DefaultRules)
+	if err != nil {
+		t.Fatalf("NewWordFilter() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "No bad words", input: "I love coding in go", want: "I love coding in go"},
+		{name: "Punctuation", input: "Sharbert! that was an awesome Fornax@", want: "****! that was an awesome ****@"},
+		{name: "Case folding", input: "KERFUFFLE and ſharbert", want: "**** and ****"},
+		{name: "Leetspeak", input: "what a k3rfuff1e, $harb3r7", want: "what a ****, ****"},
+		{name: "Hashtag", input: "#fornax", want: "#****"},
+		{name: "Zero width space", input: "forn\u200bax", want: "****"},
+		{name: "Longer word", input: "fornaxes are fine", want: "fornaxes are fine"},
+		{name: "Symbol between words", input: "fornax!sharbert", want: "****!****"},
+		{name: "Leetspeak symbol between words", input: "fornax@sharbert", want: "****@****"},
+		{name: "Symbol inside a longer word", input: "fornax|es", want: "****|es"},
+		{name: "Empty", input: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filter.Check(tt.input).Text; got != tt.want {
+				t.Errorf("Check(%q).Text = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWordFilterActions(t *testing.T) {
+	filter, err := NewWordFilter([]Rule{
+		{Term: "fornax", Action: ActionMask},
+		{Term: "grawlix", Action: ActionFlag},
+		{Term: "frell", Action: ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("NewWordFilter() error = %v", err)
+	}
+
+	result := filter.Check("Fornax, what a GRAWLIX")
+	if result.Text != "****, what a GRAWLIX" {
+		t.Errorf("Text = %q, want only the masked word replaced", result.Text)
+	}
+	if !result.Flagged() || result.Rejected() {
+		t.Errorf("Flagged() = %v, Rejected() = %v, want only flagged", result.Flagged(), result.Rejected())
+	}
+	if len(result.Matches) != 2 || result.Matches[1].Word != "GRAWLIX" {
+		t.Errorf("Matches = %+v, want fornax then GRAWLIX", result.Matches)
+	}
+
+	if !filter.Check("oh fr3ll").Rejected() {
+		t.Error("Expected a reject rule to reject the text")
+	}
+}
+
+func TestWordFilterKeepsIAndLApart(t *testing.T) {
+	filter, err := NewWordFilter([]Rule{{Term: "hell", Action: ActionMask}})
+	if err != nil {
+		t.Fatalf("NewWordFilter() error = %v", err)
+	}
+
+	tests := map[string]string{
+		"hell":  "****",
+		"he11":  "****",
+		"he|!":  "****",
+		"heil":  "heil",
+		"he1i":  "he1i",
+		"hello": "hello",
+	}
+	for input, want := range tests {
+		if got := filter.Check(input).Text; got != want {
+			t.Errorf("Check(%q).Text = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestWordFilterMaskIsNeverLonger(t *testing.T) {
+	filter, err := NewWordFilter([]Rule{
+		{Term: "ugh", Action: ActionMask},
+		{Term: "x", Action: ActionMask},
+	})
+	if err != nil {
+		t.Fatalf("NewWordFilter() error = %v", err)
+	}
+
+	input := strings.Repeat("ugh x ", 23) + "ug"
+	got := filter.Check(input).Text
+	want := strings.Repeat("*** * ", 23) + "ug"
+	if got != want {
+		t.Errorf("Check().Text = %q, want %q", got, want)
+	}
+	if len(got) > len(input) {
+		t.Errorf("Masking made the text longer: %d > %d", len(got), len(input))
+	}
+}
+
+func TestNewWordFilterLaterRulesWin(t *testing.T) {
+	filter, err := NewWordFilter([]Rule{
+		{Term: "fornax", Action: ActionMask},
+		{Term: "Fornax", Action: ActionReject},
+	})
+	if err != nil {
+		t.Fatalf("NewWordFilter() error = %v", err)
+	}
+	if !filter.Check("fornax").Rejected() {
+		t.Error("Expected the later rule to override the earlier one")
+	}
+
+	_, err = NewWordFilter([]Rule{{Term: "two words", Action: ActionMask}})
+	if err == nil {
+		t.Error("Expected a term with a space to be rejected")
+	}
+	_, err = NewWordFilter([]Rule{{Term: "fornax", Action: "delete"}})
+	if err == nil {
+		t.Error("Expected an unknown action to be rejected")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	first, _ := NewWordFilter([]Rule{{Term: "fornax", Action: ActionMask}})
+	second, _ := NewWordFilter([]Rule{{Term: "grawlix", Action: ActionFlag}})
+
+	result := Pipeline{first, second}.Check("fornax grawlix")
+	if result.Text != "**** grawlix" || len(result.Matches) != 2 || !result.Flagged() {
+		t.Errorf("Check() = %+v", result)
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	input := `# Word list
+kerfuffle
+sharbert   flag
+
+fornax reject
+`
+	rules, err := ReadRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ReadRules() error = %v", err)
+	}
+	want := []Rule{
+		{Term: "kerfuffle", Action: ActionMask},
+		{Term: "sharbert", Action: ActionFlag},
+		{Term: "fornax", Action: ActionReject},
+	}
+	if len(rules) != len(want) {
+		t.Fatalf("ReadRules() = %+v, want %+v", rules, want)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rules[%d] = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+
+	_, err = ReadRules(strings.NewReader("fornax\nsharbert delete\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("ReadRules() error = %v, want one pointing at line 2", err)
+	}
+}
@@ -0,0 +1,71 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultRules are used when no word list is configured.
+var DefaultRules = []Rule{
+	{Term: "kerfuffle", Action: ActionMask},
+	{Term: "sharbert", Action: ActionMask},
+	{Term: "fornax", Action: ActionMask},
+}
+
+// ReadRules parses a word list: one term per line, optionally followed by
+// its action, which defaults to mask. Blank lines and lines starting with #
+// are skipped.
+//
+//	# Masked
+//	kerfuffle
+//	sharbert mask
+//	fornax reject
+func ReadRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: want a term and an optional action", line)
+		}
+
+		rule := Rule{Term: fields[0], Action: ActionMask}
+		if len(fields) == 2 {
+			action, err := ParseAction(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			rule.Action = action
+		}
+		_, err := NormalizeTerm(rule.Term)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// ReadRulesFile reads a word list from the file at path.
+func ReadRulesFile(path string) ([]Rule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules, err := ReadRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
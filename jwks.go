@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+)
+
+const defaultJWTAudience = "chirpy"
+
+// loadJWTKeys builds the access token key set from the environment:
+//
+//   - JWT_SIGNING_KEY_FILE is the PEM private key new tokens are signed with.
+//   - JWT_RETIRED_KEY_FILES is a comma separated list of PEM keys that were
+//     rotated out but still verify tokens issued before the rotation.
+//   - JWT_AUDIENCE is the aud claim tokens are issued for and must carry.
+//
+// In dev a missing signing key is replaced with a throwaway one, which logs
+// everyone out on restart.
+func loadJWTKeys(isDev bool) (*auth.KeySet, error) {
+	audience := os.Getenv("JWT_AUDIENCE")
+	if audience == "" {
+		audience = defaultJWTAudience
+	}
+
+	var signer crypto.Signer
+	signingKeyFile := os.Getenv("JWT_SIGNING_KEY_FILE")
+	switch {
+	case signingKeyFile != "":
+		data, err := os.ReadFile(signingKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err = auth.ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", signingKeyFile, err)
+		}
+	case isDev:
+		var err error
+		signer, err = auth.GenerateSigningKey()
+		if err != nil {
+			return nil, err
+		}
+		log.Print("JWT_SIGNING_KEY_FILE isn't set; signing access tokens with a temporary key")
+	default:
+		return nil, errors.New("JWT_SIGNING_KEY_FILE must be set")
+	}
+
+	var retired []crypto.PublicKey
+	for _, path := range strings.Split(os.Getenv("JWT_RETIRED_KEY_FILES"), ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		public, err := auth.ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		retired = append(retired, public)
+	}
+
+	return auth.NewKeySet(audience, signer, retired...)
+}
+
+func (cfg *apiConfig) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	respondWithJSON(w, http.StatusOK, cfg.jwtKeys.JWKS())
+}
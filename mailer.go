@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/mail"
+)
+
+const (
+	defaultMailFrom = "Chirpy <no-reply@localhost>"
+	defaultBaseURL  = "http://localhost:8080"
+	mailSendTimeout = 30 * time.Second
+)
+
+// newMailer picks how emails are delivered from MAILER: "smtp" sends them
+// through SMTP_ADDR, "file" writes them to MAIL_DIR and "log" prints them.
+// Printed and written emails include live reset and verification links, so
+// MAILER can only be left unset, meaning "log", in development.
+func newMailer(dev bool) (mail.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = defaultMailFrom
+	}
+
+	switch kind := os.Getenv("MAILER"); kind {
+	case "":
+		if !dev {
+			return nil, errors.New("MAILER must be set to smtp, file or log")
+		}
+		return mail.LogMailer{}, nil
+	case "log":
+		return mail.LogMailer{}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return mail.NewFileMailer(dir, from)
+	case "smtp":
+		addr := os.Getenv("SMTP_ADDR")
+		if addr == "" {
+			return nil, errors.New("SMTP_ADDR must be set when MAILER is smtp")
+		}
+		return mail.NewSMTPMailer(addr, from, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	default:
+		return nil, fmt.Errorf("unknown MAILER %q", kind)
+	}
+}
+
+// sendMail delivers msg in the background, so handlers don't wait on the
+// mail server. That alone doesn't keep response times from showing whether
+// an email goes out: handlers that mustn't give that away, like
+// forgotPasswordHandler, also do the lookups that decide it in the
+// background.
+func (cfg *apiConfig) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+		err := cfg.mailer.Send(ctx, msg)
+		if err != nil {
+			log.Printf("Error sending %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/mail"
+)
+
+func TestNewMailerDefaultsToLogOnlyInDev(t *testing.T) {
+	t.Setenv("MAILER", "")
+
+	_, err := newMailer(false)
+	if err == nil {
+		t.Error("Expected MAILER to be required outside development")
+	}
+
+	mailer, err := newMailer(true)
+	if err != nil {
+		t.Fatalf("newMailer(dev) error = %v", err)
+	}
+	if _, ok := mailer.(mail.LogMailer); !ok {
+		t.Errorf("newMailer(dev) = %T, want mail.LogMailer", mailer)
+	}
+}
@@ -1,34 +1,54 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/ericksotoe/chirpy/internal/auth"
 	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/mail"
+	"github.com/ericksotoe/chirpy/internal/media"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Username      *string   `json:"username"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
 }
 
 type apiConfig struct {
 	db             *database.Queries
+	conn           *sql.DB
 	fileserverHits atomic.Int32
 	dev            string
-	secret         string
+	jwtKeys        *auth.KeySet
 	polkaApiKey    string
+	editWindow     time.Duration
+	storage        media.Storage
+	stream         *chirpStream
+	mailer         mail.Mailer
+	baseURL        string
+	// verifiedOnly stops users who haven't verified their email address from
+	// posting chirps.
+	verifiedOnly bool
+	// deletionGrace is how long a deleted account is kept, so it can be
+	// restored by logging in, before it's purged.
+	deletionGrace time.Duration
+	moderator     *contentModerator
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
@@ -53,7 +73,6 @@ func main() {
 	godotenv.Load()
 	isDev := os.Getenv("PLATFORM")
 	dbURL := os.Getenv("DB_URL")
-	jwtSecret := os.Getenv("SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
 
 	if dbURL == "" {
@@ -64,37 +83,182 @@ func main() {
 		fmt.Printf("Error opening the database %v", err)
 		os.Exit(1)
 	}
-	if jwtSecret == "" {
-		log.Fatal("Error: JWT Secret not found")
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			err = runMigrateCommand(context.Background(), dbConnection, os.Args[2:])
+		case "create-admin":
+			err = ensureSchema(context.Background(), dbConnection, false)
+			if err == nil {
+				err = runCreateAdminCommand(context.Background(), dbConnection, os.Stdin, os.Args[2:])
+			}
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	err = ensureSchema(context.Background(), dbConnection, os.Getenv("AUTO_MIGRATE") == "true")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	jwtKeys, err := loadJWTKeys(isDev == "dev")
+	if err != nil {
+		log.Fatalf("Error: couldn't load the JWT keys: %v", err)
 	}
 	if polkaKey == "" {
 		log.Fatal("Error: Polka api key not found ")
 	}
 
+	editWindow := defaultChirpEditWindow
+	if editWindowString := os.Getenv("CHIRP_EDIT_WINDOW"); editWindowString != "" {
+		editWindow, err = time.ParseDuration(editWindowString)
+		if err != nil {
+			log.Fatalf("Error: CHIRP_EDIT_WINDOW is not a valid duration: %v", err)
+		}
+	}
+
+	deletionGrace := defaultAccountDeletionGrace
+	if deletionGraceString := os.Getenv("ACCOUNT_DELETION_GRACE"); deletionGraceString != "" {
+		deletionGrace, err = time.ParseDuration(deletionGraceString)
+		if err != nil {
+			log.Fatalf("Error: ACCOUNT_DELETION_GRACE is not a valid duration: %v", err)
+		}
+	}
+
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	storage, err := media.NewLocalStorage(mediaDir, "/media")
+	if err != nil {
+		log.Fatalf("Error: couldn't prepare the media directory: %v", err)
+	}
+
+	mailer, err := newMailer(isDev == "dev")
+	if err != nil {
+		log.Fatalf("Error: couldn't set up the mailer: %v", err)
+	}
+	baseURL := strings.TrimSuffix(os.Getenv("BASE_URL"), "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
 	dbQ := database.New(dbConnection)
+	denylist, err := newDenylist(dbQ)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	jwtKeys.UseDenylist(denylist)
+
+	moderator, err := newContentModerator(context.Background(), dbQ)
+	if err != nil {
+		log.Fatalf("Error: couldn't load the moderation rules: %v", err)
+	}
+
 	apiCfg := apiConfig{
 		db:             dbQ,
+		conn:           dbConnection,
 		fileserverHits: atomic.Int32{},
 		dev:            isDev,
-		secret:         jwtSecret,
+		jwtKeys:        jwtKeys,
 		polkaApiKey:    polkaKey,
+		editWindow:     editWindow,
+		storage:        storage,
+		stream:         newChirpStream(),
+		mailer:         mailer,
+		baseURL:        baseURL,
+		verifiedOnly:   os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		deletionGrace:  deletionGrace,
+		moderator:      moderator,
+	}
+
+	// LISTEN needs a dedicated connection, so the listener dials its own
+	// rather than borrowing one from the pool.
+	listener := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Chirp event listener: %v", err)
+		}
+	})
+	err = listener.Listen(chirpEventsChannel)
+	if err != nil {
+		log.Fatalf("Error listening for chirp events: %v", err)
+	}
+	go apiCfg.runChirpStream(listener)
+	go apiCfg.runAccountPurge()
+	go apiCfg.runMediaSweep()
+	go apiCfg.runModerationRefresh()
+
+	// Routes that need a logged in user go through authenticated. Public
+	// routes that show more to a logged in user, like liked_by_me, go through
+	// optionalAuth.
+	authenticated := func(handler http.HandlerFunc) http.Handler {
+		return apiCfg.middlewareAuthenticate(handler)
+	}
+	optionalAuth := func(handler http.HandlerFunc) http.Handler {
+		return apiCfg.middlewareOptionalAuth(handler)
 	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/app/", http.StripPrefix("/app", apiCfg.middlewareMetricsInc(http.FileServer(http.Dir(".")))))
-	mux.HandleFunc("GET /admin/metrics", apiCfg.requestCountHandler)
+	mux.Handle("GET /media/", http.StripPrefix("/media", http.FileServer(http.Dir(mediaDir))))
+	mux.Handle("GET /admin/metrics", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.requestCountHandler)))
 	mux.HandleFunc("GET /api/healthz", readinessHandler)
-	mux.HandleFunc("GET /api/chirps/", apiCfg.getChirpsHandler)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirpsByIDHandler)
-	mux.HandleFunc("POST /admin/reset", apiCfg.requestResetHandler)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.jwksHandler)
+	mux.Handle("GET /api/chirps/", optionalAuth(apiCfg.getChirpsHandler))
+	mux.Handle("GET /api/chirps/search", optionalAuth(apiCfg.searchChirpsHandler))
+	mux.Handle("GET /api/chirps/{chirpID}", optionalAuth(apiCfg.getChirpsByIDHandler))
+	mux.Handle("GET /api/chirps/{chirpID}/thread", optionalAuth(apiCfg.getChirpThreadHandler))
+	mux.Handle("POST /admin/reset", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.requestResetHandler)))
+	mux.Handle("PUT /admin/users/{userID}/role", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.setUserRoleHandler)))
+	mux.Handle("GET /admin/moderation/rules", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.getModerationRulesHandler)))
+	mux.Handle("PUT /admin/moderation/rules/{term}", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.putModerationRuleHandler)))
+	mux.Handle("DELETE /admin/moderation/rules/{term}", apiCfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(apiCfg.deleteModerationRuleHandler)))
+	mux.Handle("GET /admin/moderation/flags", apiCfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(apiCfg.getChirpFlagsHandler)))
+	mux.Handle("DELETE /admin/moderation/flags/{chirpID}", apiCfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(apiCfg.dismissChirpFlagsHandler)))
 	mux.HandleFunc("POST /api/users", apiCfg.createUserHandler)
-	mux.HandleFunc("POST /api/chirps", apiCfg.createChirpHandler)
+	mux.Handle("POST /api/chirps", authenticated(apiCfg.createChirpHandler))
+	mux.Handle("POST /api/media", authenticated(apiCfg.uploadMediaHandler))
 	mux.HandleFunc("POST /api/login", apiCfg.loginUserHandler)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.loginTwoFactorHandler)
+	mux.Handle("POST /api/2fa/enroll", authenticated(apiCfg.enrollTwoFactorHandler))
+	mux.Handle("POST /api/2fa/confirm", authenticated(apiCfg.confirmTwoFactorHandler))
+	mux.Handle("POST /api/2fa/disable", authenticated(apiCfg.disableTwoFactorHandler))
 	mux.HandleFunc("POST /api/refresh", apiCfg.refreshTokenHandler)
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeTokenHandler)
+	mux.Handle("POST /api/logout", authenticated(apiCfg.logoutHandler))
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.forgotPasswordHandler)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.resetPasswordHandler)
+	mux.HandleFunc("POST /api/email/verify", apiCfg.verifyEmailHandler)
+	mux.Handle("POST /api/email/resend", authenticated(apiCfg.resendVerificationHandler))
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.addChirpyRedHandler)
-	mux.HandleFunc("PUT /api/users", apiCfg.updateUserHandler)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpHandler)
+	mux.Handle("PUT /api/users", authenticated(apiCfg.updateUserHandler))
+	mux.Handle("PATCH /api/users", authenticated(apiCfg.patchUserHandler))
+	mux.Handle("DELETE /api/users", authenticated(apiCfg.deleteUserHandler))
+	mux.Handle("GET /api/users/me/export", authenticated(apiCfg.exportUserHandler))
+	mux.Handle("DELETE /api/chirps/{chirpID}", authenticated(apiCfg.deleteChirpHandler))
+	mux.Handle("PUT /api/chirps/{chirpID}", authenticated(apiCfg.updateChirpHandler))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.getChirpRevisionsHandler)
+	mux.Handle("POST /api/chirps/{chirpID}/like", authenticated(apiCfg.engagementHandler(apiCfg.likeChirp)))
+	mux.Handle("DELETE /api/chirps/{chirpID}/like", authenticated(apiCfg.engagementHandler(apiCfg.unlikeChirp)))
+	mux.Handle("POST /api/chirps/{chirpID}/rechirp", authenticated(apiCfg.engagementHandler(apiCfg.rechirp)))
+	mux.Handle("DELETE /api/chirps/{chirpID}/rechirp", authenticated(apiCfg.engagementHandler(apiCfg.undoRechirp)))
+	mux.Handle("POST /api/users/{userID}/follow", authenticated(apiCfg.followUserHandler))
+	mux.Handle("DELETE /api/users/{userID}/follow", authenticated(apiCfg.unfollowUserHandler))
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.getFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.getFollowingHandler)
+	mux.Handle("GET /api/timeline", authenticated(apiCfg.getTimelineHandler))
+	mux.Handle("GET /api/stream", optionalAuth(apiCfg.streamHandler))
+	mux.Handle("GET /api/tags/{tag}/chirps", optionalAuth(apiCfg.getTagChirpsHandler))
+	mux.Handle("GET /api/users/{userID}/mentions", optionalAuth(apiCfg.getUserMentionsHandler))
+	mux.Handle("GET /api/sessions", authenticated(apiCfg.getSessionsHandler))
+	mux.Handle("DELETE /api/sessions/{sessionID}", authenticated(apiCfg.revokeSessionHandler))
+	mux.Handle("POST /api/sessions/revoke-all", authenticated(apiCfg.revokeOtherSessionsHandler))
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/media"
+	"github.com/google/uuid"
+)
+
+const (
+	// unattachedMediaTTL is how long an upload can wait to be attached to a
+	// chirp before it's deleted.
+	unattachedMediaTTL = 24 * time.Hour
+	mediaSweepEvery    = time.Hour
+)
+
+type MediaResponse struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+}
+
+func (cfg *apiConfig) mediaResponseFromDB(medium database.Medium) MediaResponse {
+	return MediaResponse{
+		ID:           medium.ID,
+		URL:          cfg.storage.URL(medium.StorageKey),
+		ThumbnailURL: cfg.storage.URL(medium.ThumbnailKey),
+		ContentType:  medium.ContentType,
+		Width:        medium.Width,
+		Height:       medium.Height,
+	}
+}
+
+func (cfg *apiConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	// Leave some room for the multipart framing around the file itself.
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadBytes+(1<<20))
+	err := r.ParseMultipartForm(media.MaxUploadBytes)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Upload is too large")
+		return
+	}
+	if err != nil {
+		respondWithProblem(w, malformedBody("Upload isn't multipart form data"))
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Upload must include a file field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadBytes+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read the uploaded file")
+		return
+	}
+
+	img, err := media.Process(r.Context(), data)
+	if errors.Is(err, media.ErrTooLarge) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Uploaded file isn't a valid image")
+		return
+	}
+
+	mediaID := uuid.New()
+	storageKey := mediaID.String() + img.Extension
+	thumbnailKey := mediaID.String() + "_thumb" + img.Extension
+
+	err = cfg.storage.Save(r.Context(), storageKey, bytes.NewReader(img.Data))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store the image")
+		return
+	}
+	err = cfg.storage.Save(r.Context(), thumbnailKey, bytes.NewReader(img.Thumbnail))
+	if err != nil {
+		cfg.storage.Delete(r.Context(), storageKey)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't store the thumbnail")
+		return
+	}
+
+	medium, err := cfg.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           mediaID,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+	})
+	if err != nil {
+		cfg.storage.Delete(r.Context(), storageKey)
+		cfg.storage.Delete(r.Context(), thumbnailKey)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the image")
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, cfg.mediaResponseFromDB(medium))
+}
+
+// fillMedia loads the attachments for every chirp with a single query.
+func (cfg *apiConfig) fillMedia(ctx context.Context, chirps []*ChirpResponse) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbMedia, err := cfg.db.ListMediaForChirps(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+
+	byChirp := map[uuid.UUID][]MediaResponse{}
+	for _, medium := range dbMedia {
+		byChirp[medium.ChirpID.UUID] = append(byChirp[medium.ChirpID.UUID], cfg.mediaResponseFromDB(medium))
+	}
+	for _, chirp := range chirps {
+		chirp.Media = byChirp[chirp.ID]
+		if chirp.Media == nil {
+			chirp.Media = []MediaResponse{}
+		}
+	}
+	return nil
+}
+
+// hydrateChirps fills in the fields of ChirpResponse that live outside the
+// chirps table: attached media and, for authenticated callers, LikedByMe.
+func (cfg *apiConfig) hydrateChirps(r *http.Request, chirps []*ChirpResponse) error {
+	err := cfg.fillMedia(r.Context(), chirps)
+	if err != nil {
+		return err
+	}
+	return cfg.markLikedForCaller(r, chirps)
+}
+
+// deleteMediaFiles removes files from storage once the rows pointing at them
+// are gone. Failures are only logged: the rows are already deleted, so
+// there's nothing left for the client to retry.
+func (cfg *apiConfig) deleteMediaFiles(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		err := cfg.storage.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Error deleting media file %s: %v", key, err)
+		}
+	}
+}
+
+// runMediaSweep deletes uploads that were never attached to a chirp, once at
+// startup and then every mediaSweepEvery.
+func (cfg *apiConfig) runMediaSweep() {
+	ticker := time.NewTicker(mediaSweepEvery)
+	defer ticker.Stop()
+
+	for {
+		cfg.sweepUnattachedMedia(context.Background())
+		<-ticker.C
+	}
+}
+
+func (cfg *apiConfig) sweepUnattachedMedia(ctx context.Context) {
+	abandoned, err := cfg.db.DeleteUnattachedMediaBefore(ctx, time.Now().Add(-unattachedMediaTTL))
+	if err != nil {
+		log.Printf("Error deleting unattached media: %v", err)
+		return
+	}
+	for _, medium := range abandoned {
+		cfg.deleteMediaFiles(ctx, medium.StorageKey, medium.ThumbnailKey)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ericksotoe/chirpy/sql/schema"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+const migrateUsage = "usage: chirpy migrate up|down|status"
+
+// newMigrationProvider returns a goose provider over the embedded
+// migrations. The session lock keeps several instances that start at once
+// from migrating concurrently.
+func newMigrationProvider(db *sql.DB) (*goose.Provider, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(goose.DialectPostgres, db, schema.FS, goose.WithSessionLocker(locker))
+}
+
+func runMigrateCommand(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	provider, err := newMigrationProvider(db)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := provider.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Println("No migrations to apply")
+		}
+		for _, result := range results {
+			fmt.Printf("Applied %s (%s)\n", result.Source.Path, result.Duration)
+		}
+	case "down":
+		result, err := provider.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			fmt.Println("No migrations to roll back")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %s (%s)\n", result.Source.Path, result.Duration)
+	case "status":
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return err
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(out, "VERSION\tSTATE\tAPPLIED AT\tSOURCE")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, appliedAt, status.Source.Path)
+		}
+		return out.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
+
+// ensureSchema optionally applies pending migrations and then refuses to
+// continue if the database is older than the newest embedded migration.
+func ensureSchema(ctx context.Context, db *sql.DB, autoMigrate bool) error {
+	provider, err := newMigrationProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if autoMigrate {
+		_, err := provider.Up(ctx)
+		if err != nil {
+			return fmt.Errorf("applying migrations: %w", err)
+		}
+	}
+
+	sources := provider.ListSources()
+	if len(sources) == 0 {
+		return nil
+	}
+	expected := sources[len(sources)-1].Version
+
+	current, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if current < expected {
+		return fmt.Errorf("database schema is at version %d but this binary expects %d; run `chirpy migrate up` or set AUTO_MIGRATE=true", current, expected)
+	}
+	return nil
+}
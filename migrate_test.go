@@ -0,0 +1,29 @@
+package main
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/ericksotoe/chirpy/sql/schema"
+	"github.com/pressly/goose/v3"
+)
+
+func TestEmbeddedMigrationsAreSequential(t *testing.T) {
+	files, err := fs.Glob(schema.FS, "*.sql")
+	if err != nil {
+		t.Fatalf("listing embedded migrations: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no migrations were embedded")
+	}
+
+	for i, file := range files {
+		version, err := goose.NumericComponent(file)
+		if err != nil {
+			t.Fatalf("migration %s has no version prefix: %v", file, err)
+		}
+		if version != int64(i+1) {
+			t.Errorf("migration %s has version %d, want %d", file, version, i+1)
+		}
+	}
+}
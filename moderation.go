@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/moderation"
+	"github.com/google/uuid"
+)
+
+const (
+	moderationRefreshEvery = time.Minute
+	chirpFlagsPageSize     = 100
+)
+
+type ModerationRuleResponse struct {
+	Term   string `json:"term"`
+	Action string `json:"action"`
+	// Source is "file" for rules from the word list file and "database" for
+	// rules added through the API, which can be changed or deleted.
+	Source string `json:"source"`
+}
+
+type FlaggedChirpResponse struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+	Terms     []string  `json:"terms"`
+	FlaggedAt time.Time `json:"flagged_at"`
+}
+
+type moderationRuleChange struct {
+	Action string `json:"action" validate:"required"`
+}
+
+// contentModerator is the moderation.Filter chirps are checked with. Its
+// rules are the word list file's, or moderation.DefaultRules without one,
+// overridden by the rules stored in the database.
+type contentModerator struct {
+	fileRules []moderation.Rule
+	filter    atomic.Pointer[moderation.WordFilter]
+}
+
+// newContentModerator loads the word list named by MODERATION_WORDS_FILE
+// and the rules stored in the database.
+func newContentModerator(ctx context.Context, db *database.Queries) (*contentModerator, error) {
+	fileRules := moderation.DefaultRules
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		var err error
+		fileRules, err = moderation.ReadRulesFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m := &contentModerator{fileRules: fileRules}
+	err := m.reload(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *contentModerator) Check(text string) moderation.Result {
+	return m.filter.Load().Check(text)
+}
+
+// reload rebuilds the filter from the rules currently in the database.
+func (m *contentModerator) reload(ctx context.Context, db *database.Queries) error {
+	stored, err := db.ListModerationRules(ctx)
+	if err != nil {
+		return err
+	}
+	return m.update(stored)
+}
+
+func (m *contentModerator) update(stored []database.ModerationRule) error {
+	rules := slices.Clone(m.fileRules)
+	for _, rule := range stored {
+		rules = append(rules, moderation.Rule{Term: rule.Term, Action: moderation.Action(rule.Action)})
+	}
+	filter, err := moderation.NewWordFilter(rules)
+	if err != nil {
+		return err
+	}
+	m.filter.Store(filter)
+	return nil
+}
+
+// runModerationRefresh reloads the moderation rules every
+// moderationRefreshEvery, so changes made through another instance are
+// picked up. Changes made through this one apply right away.
+func (cfg *apiConfig) runModerationRefresh() {
+	ticker := time.NewTicker(moderationRefreshEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		err := cfg.moderator.reload(context.Background(), cfg.db)
+		if err != nil {
+			log.Printf("Error reloading the moderation rules: %v", err)
+		}
+	}
+}
+
+// moderateChirpBody checks a chirp body before it's saved. It returns the
+// body with masked words replaced, or writes the error response itself when
+// the body matched a reject rule and reports that the handler should stop.
+func (cfg *apiConfig) moderateChirpBody(w http.ResponseWriter, body string) (moderation.Result, bool) {
+	result := cfg.moderator.Check(body)
+	if result.Rejected() {
+		respondWithProblem(w, invalidField("body", "body contains language that isn't allowed"))
+		return moderation.Result{}, false
+	}
+	return result, true
+}
+
+// saveChirpFlags queues a chirp for review for every flag rule its body
+// matched.
+func saveChirpFlags(ctx context.Context, q *database.Queries, chirpID uuid.UUID, result moderation.Result) error {
+	for _, match := range result.Matches {
+		if match.Rule.Action != moderation.ActionFlag {
+			continue
+		}
+		err := q.FlagChirp(ctx, database.FlagChirpParams{
+			ChirpID: chirpID,
+			Term:    match.Rule.Term,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moderationTerm reads the term from the request path the way it's stored:
+// trimmed and lowercased. It writes the error response itself and reports
+// whether the handler should continue.
+func moderationTerm(w http.ResponseWriter, r *http.Request) (string, bool) {
+	term := strings.ToLower(strings.TrimSpace(r.PathValue("term")))
+	_, err := moderation.NormalizeTerm(term)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Term must be a single word")
+		return "", false
+	}
+	return term, true
+}
+
+func (cfg *apiConfig) getModerationRulesHandler(w http.ResponseWriter, r *http.Request) {
+	stored, err := cfg.db.ListModerationRules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the moderation rules")
+		return
+	}
+
+	rules := make([]ModerationRuleResponse, 0, len(cfg.moderator.fileRules)+len(stored))
+	for _, rule := range cfg.moderator.fileRules {
+		rules = append(rules, ModerationRuleResponse{Term: rule.Term, Action: string(rule.Action), Source: "file"})
+	}
+	for _, rule := range stored {
+		rules = append(rules, ModerationRuleResponse{Term: rule.Term, Action: rule.Action, Source: "database"})
+	}
+	respondWithJSON(w, http.StatusOK, rules)
+}
+
+// putModerationRuleHandler adds a rule for a term, or changes the action of
+// the one already stored. A rule for a term in the word list file overrides
+// the file's action.
+func (cfg *apiConfig) putModerationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	term, ok := moderationTerm(w, r)
+	if !ok {
+		return
+	}
+
+	params := moderationRuleChange{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+	action, err := moderation.ParseAction(params.Action)
+	if err != nil {
+		respondWithProblem(w, invalidField("action", "action must be mask, reject or flag"))
+		return
+	}
+
+	rule, err := cfg.db.UpsertModerationRule(r.Context(), database.UpsertModerationRuleParams{
+		Term:   term,
+		Action: string(action),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the moderation rule")
+		return
+	}
+
+	err = cfg.moderator.reload(r.Context(), cfg.db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply the moderation rules")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ModerationRuleResponse{Term: rule.Term, Action: rule.Action, Source: "database"})
+}
+
+// deleteModerationRuleHandler removes a rule added through the API. Terms
+// in the word list file can only be removed by editing the file.
+func (cfg *apiConfig) deleteModerationRuleHandler(w http.ResponseWriter, r *http.Request) {
+	term, ok := moderationTerm(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := cfg.db.DeleteModerationRule(r.Context(), term)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the moderation rule")
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "There's no stored rule for this term")
+		return
+	}
+
+	err = cfg.moderator.reload(r.Context(), cfg.db)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't apply the moderation rules")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getChirpFlagsHandler lists the flagged chirps waiting for review, oldest
+// first, with every term each one was flagged for. FlaggedAt is when the
+// chirp was first flagged.
+func (cfg *apiConfig) getChirpFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	rows, err := cfg.db.ListChirpFlags(r.Context(), chirpFlagsPageSize)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the flagged chirps")
+		return
+	}
+
+	flagged := []FlaggedChirpResponse{}
+	positions := map[uuid.UUID]int{}
+	for _, row := range rows {
+		i, seen := positions[row.ChirpID]
+		if !seen {
+			i = len(flagged)
+			positions[row.ChirpID] = i
+			flagged = append(flagged, FlaggedChirpResponse{
+				ChirpID:   row.ChirpID,
+				UserID:    row.UserID,
+				Body:      row.Body,
+				Terms:     []string{},
+				FlaggedAt: row.FirstFlaggedAt,
+			})
+		}
+		flagged[i].Terms = append(flagged[i].Terms, row.Term)
+	}
+	respondWithJSON(w, http.StatusOK, flagged)
+}
+
+// dismissChirpFlagsHandler marks a flagged chirp as reviewed.
+func (cfg *apiConfig) dismissChirpFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp Id")
+		return
+	}
+
+	dismissed, err := cfg.db.DismissChirpFlags(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't dismiss the flags")
+		return
+	}
+	if dismissed == 0 {
+		respondWithError(w, http.StatusNotFound, "Chirp isn't flagged")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
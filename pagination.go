@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+// ChirpPage is the envelope returned by every paginated chirp listing.
+// NextCursor is null once the last page has been reached.
+type ChirpPage struct {
+	Chirps     []ChirpResponse `json:"chirps"`
+	NextCursor *string         `json:"next_cursor"`
+}
+
+// chirpCursor marks the position of the last chirp a client has seen.
+// It is handed out base64 encoded so clients treat it as opaque.
+type chirpCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+type pageRequest struct {
+	limit  int32
+	desc   bool
+	cursor *chirpCursor
+}
+
+func encodeCursor(c chirpCursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (chirpCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return chirpCursor{}, errors.New("cursor is not valid base64")
+	}
+
+	createdAtString, idString, found := strings.Cut(string(raw), "|")
+	if !found {
+		return chirpCursor{}, errors.New("cursor is malformed")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtString)
+	if err != nil {
+		return chirpCursor{}, errors.New("cursor has an invalid timestamp")
+	}
+
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return chirpCursor{}, errors.New("cursor has an invalid id")
+	}
+
+	return chirpCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// parsePageRequest reads the limit, cursor and sort query parameters shared
+// by every chirp listing endpoint.
+func parsePageRequest(r *http.Request) (pageRequest, error) {
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		return pageRequest{}, err
+	}
+	page := pageRequest{limit: limit}
+
+	switch query.Get("sort") {
+	case "", "asc":
+	case "desc":
+		page.desc = true
+	default:
+		return pageRequest{}, errors.New("sort must be asc or desc")
+	}
+
+	if cursorString := query.Get("cursor"); cursorString != "" {
+		cursor, err := decodeCursor(cursorString)
+		if err != nil {
+			return pageRequest{}, err
+		}
+		page.cursor = &cursor
+	}
+
+	return page, nil
+}
+
+// parsePageLimit reads the limit query parameter shared by every listing,
+// capping it at maxPageLimit.
+func parsePageLimit(query url.Values) (int32, error) {
+	limitString := query.Get("limit")
+	if limitString == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	return int32(min(limit, maxPageLimit)), nil
+}
+
+// cursorArgs converts the page cursor into the nullable query arguments
+// used by the keyset queries.
+func (p pageRequest) cursorArgs() (sql.NullTime, uuid.NullUUID) {
+	if p.cursor == nil {
+		return sql.NullTime{}, uuid.NullUUID{}
+	}
+	return sql.NullTime{Time: p.cursor.CreatedAt, Valid: true},
+		uuid.NullUUID{UUID: p.cursor.ID, Valid: true}
+}
+
+// fetchLimit asks the database for one extra row so we can tell whether
+// another page exists without a separate COUNT query.
+func (p pageRequest) fetchLimit() int32 {
+	return p.limit + 1
+}
+
+// buildChirpPage trims the extra row fetched by fetchLimit and fills in the
+// cursor for the next page.
+func buildChirpPage(dbChirps []database.Chirp, page pageRequest) ChirpPage {
+	result := ChirpPage{Chirps: []ChirpResponse{}}
+
+	hasMore := len(dbChirps) > int(page.limit)
+	if hasMore {
+		dbChirps = dbChirps[:page.limit]
+	}
+
+	for _, dbChirp := range dbChirps {
+		result.Chirps = append(result.Chirps, chirpResponseFromDB(dbChirp))
+	}
+
+	if hasMore {
+		last := dbChirps[len(dbChirps)-1]
+		next := encodeCursor(chirpCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+		result.NextCursor = &next
+	}
+
+	return result
+}
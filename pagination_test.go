@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := chirpCursor{
+		CreatedAt: time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC),
+		ID:        uuid.New(),
+	}
+
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decodeCursor() error = %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+		t.Errorf("decodeCursor() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParsePageRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     string
+		wantLimit int32
+		wantDesc  bool
+		wantErr   bool
+	}{
+		{name: "defaults", query: "", wantLimit: defaultPageLimit},
+		{name: "explicit limit and desc", query: "?limit=10&sort=desc", wantLimit: 10, wantDesc: true},
+		{name: "limit is capped", query: "?limit=5000", wantLimit: maxPageLimit},
+		{name: "zero limit", query: "?limit=0", wantErr: true},
+		{name: "bad sort", query: "?sort=sideways", wantErr: true},
+		{name: "bad cursor", query: "?cursor=not-a-cursor", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/chirps"+tt.query, nil)
+			page, err := parsePageRequest(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePageRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if page.limit != tt.wantLimit || page.desc != tt.wantDesc {
+				t.Errorf("parsePageRequest() = %+v, want limit %d desc %v", page, tt.wantLimit, tt.wantDesc)
+			}
+		})
+	}
+}
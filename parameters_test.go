@@ -2,9 +2,18 @@ package main
 
 import (
 	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/moderation"
 )
 
-func TestCleanUpBadWords(t *testing.T) {
+func TestModerateChirpBody(t *testing.T) {
+	moderator := &contentModerator{fileRules: moderation.DefaultRules}
+	err := moderator.update(nil)
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
 	// 1. Define your test cases
 	tests := []struct {
 		name     string
@@ -34,24 +43,40 @@ func TestCleanUpBadWords(t *testing.T) {
 		{
 			name:     "puncuation bad word",
 			input:    "Sharbert! that was an awesome Fornax@",
-			expected: "Sharbert! that was an awesome Fornax@",
+			expected: "****! that was an awesome ****@",
 		},
 	}
 
 	// 2. Iterate through cases
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			// Initialize the struct with the test input
-			params := &parameters{Body: tt.input}
-
 			// Call the function under test
-			cleanUpBadWords(params)
+			got := moderator.Check(tt.input).Text
 
 			// 3. Assert the results
-			if params.Body != tt.expected {
-				t.Errorf("cleanUpBadWords() failed for case '%s'\n got:  %q\n want: %q",
-					tt.name, params.Body, tt.expected)
+			if got != tt.expected {
+				t.Errorf("Check() failed for case '%s'\n got:  %q\n want: %q",
+					tt.name, got, tt.expected)
 			}
 		})
 	}
 }
+
+func TestModerationStoredRulesOverrideFile(t *testing.T) {
+	moderator := &contentModerator{fileRules: moderation.DefaultRules}
+	err := moderator.update([]database.ModerationRule{
+		{Term: "fornax", Action: string(moderation.ActionReject)},
+		{Term: "grawlix", Action: string(moderation.ActionFlag)},
+	})
+	if err != nil {
+		t.Fatalf("update() error = %v", err)
+	}
+
+	if !moderator.Check("Fornax").Rejected() {
+		t.Error("Expected the stored reject rule to override the file's mask rule")
+	}
+	result := moderator.Check("kerfuffle, grawlix")
+	if result.Text != "****, grawlix" || !result.Flagged() {
+		t.Errorf("Check() = %+v, want kerfuffle masked and the chirp flagged", result)
+	}
+}
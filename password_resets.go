@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/mail"
+)
+
+const (
+	passwordResetLifetime = time.Hour
+	// passwordResetCooldown is how long after sending a reset link another
+	// request for the same account is ignored, so the endpoint can't be used
+	// to flood someone's inbox.
+	passwordResetCooldown = time.Minute
+)
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type resetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// forgotPasswordHandler emails a reset link if the address belongs to a
+// user. It answers 202 either way, before looking the address up, so
+// neither the response nor how long it takes tells anyone who has an
+// account.
+func (cfg *apiConfig) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	params := forgotPasswordRequest{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	go cfg.sendPasswordReset(params.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendPasswordReset creates a reset token for the user with email, if there
+// is one and no link was sent to them in the last passwordResetCooldown, and
+// mails them the link.
+func (cfg *apiConfig) sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+	defer cancel()
+
+	user, err := cfg.db.GetUserUsingEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up %s for a password reset: %v", email, err)
+		return
+	}
+
+	resetToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("Error creating a password reset token: %v", err)
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("Error starting a password reset for %s: %v", user.ID, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.LockPasswordResets(ctx, user.ID)
+	if err != nil {
+		log.Printf("Error starting a password reset for %s: %v", user.ID, err)
+		return
+	}
+	recent, err := qtx.HasRecentPasswordResetToken(ctx, database.HasRecentPasswordResetTokenParams{
+		UserID:          user.ID,
+		CooldownSeconds: passwordResetCooldown.Seconds(),
+	})
+	if err != nil {
+		log.Printf("Error checking the password reset tokens of %s: %v", user.ID, err)
+		return
+	}
+	if recent {
+		return
+	}
+
+	// Only the newest link works, and expired ones are cleaned up on the way
+	err = qtx.DeletePasswordResetTokens(ctx, user.ID)
+	if err != nil {
+		log.Printf("Error deleting the password reset tokens of %s: %v", user.ID, err)
+		return
+	}
+	err = qtx.CreatePasswordResetToken(ctx, database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashRefreshToken(resetToken),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetLifetime),
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("Error saving a password reset token for %s: %v", user.ID, err)
+		return
+	}
+
+	link := cfg.baseURL + "/app/reset-password?token=" + url.QueryEscape(resetToken)
+	cfg.sendMail(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Open this link within an hour to choose a new one:\n%s\n\n"+
+			"If it wasn't you, you can ignore this email.\n", link),
+	})
+}
+
+// resetPasswordHandler sets a new password using a token from
+// forgotPasswordHandler. Every refresh token the user has is revoked, since
+// whoever knew the old password may still be logged in.
+func (cfg *apiConfig) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	params := resetPasswordRequest{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	hashedPass, err := auth.HashPassword(params.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error hashing the password passed in")
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset the password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	userID, err := qtx.UsePasswordResetToken(r.Context(), auth.HashRefreshToken(params.Token))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Reset token is invalid, expired or already used")
+		return
+	}
+
+	err = qtx.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
+		ID:             userID,
+		HashedPassword: hashedPass,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset the password")
+		return
+	}
+
+	err = qtx.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the user's sessions")
+		return
+	}
+
+	err = qtx.DeletePasswordResetTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset the password")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't reset the password")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/ericksotoe/chirpy/internal/mail"
+	"github.com/google/uuid"
+)
+
+type chanMailer chan mail.Message
+
+func (m chanMailer) Send(ctx context.Context, msg mail.Message) error {
+	m <- msg
+	return nil
+}
+
+func TestSendPasswordResetCooldown(t *testing.T) {
+	for _, recent := range []bool{false, true} {
+		cfg := newTestConfig(t)
+		mailer := make(chanMailer, 1)
+		cfg.mailer = mailer
+
+		user := database.User{ID: uuid.New(), Email: "walt@example.com", Role: "user"}
+		db := useFakeDB(t, cfg, map[string]fakeQuery{
+			"GetUserUsingEmail":           rows(userRow(user)),
+			"LockPasswordResets":          rows([]driver.Value{""}),
+			"HasRecentPasswordResetToken": rows([]driver.Value{recent}),
+			"DeletePasswordResetTokens":   affected(1),
+			"CreatePasswordResetToken":    affected(1),
+		})
+
+		cfg.sendPasswordReset(user.Email)
+
+		if db.Ran("CreatePasswordResetToken") == recent {
+			t.Errorf("recent link %v: CreatePasswordResetToken ran = %v", recent, !recent)
+		}
+		select {
+		case msg := <-mailer:
+			if recent {
+				t.Errorf("Expected no email within the cooldown, got %q", msg.Subject)
+			}
+		case <-time.After(100 * time.Millisecond):
+			if !recent {
+				t.Error("Expected a reset email")
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+)
+
+// authRealm is the realm advertised in WWW-Authenticate challenges.
+const authRealm = "chirpy"
+
+type principalContextKey struct{}
+
+// errNoCredentials means the request didn't carry an access token at all, as
+// opposed to carrying one that isn't valid.
+var errNoCredentials = errors.New("no access token")
+
+// withPrincipal returns a copy of ctx that carries the authenticated caller.
+func withPrincipal(ctx context.Context, caller auth.AccessToken) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, caller)
+}
+
+// principalFromContext returns the caller the auth middleware authenticated,
+// and false for anonymous requests.
+func principalFromContext(ctx context.Context) (auth.AccessToken, bool) {
+	caller, ok := ctx.Value(principalContextKey{}).(auth.AccessToken)
+	return caller, ok
+}
+
+// requestPrincipal returns the caller of a request served behind
+// middlewareAuthenticate. Reaching a handler without one is a routing bug,
+// not something a client can cause, so it panics.
+func requestPrincipal(r *http.Request) auth.AccessToken {
+	caller, ok := principalFromContext(r.Context())
+	if !ok {
+		panic("chirpy: " + r.Pattern + " is served without middlewareAuthenticate")
+	}
+	return caller
+}
+
+// authenticate validates the request's bearer token. It returns
+// errNoCredentials when there's no Authorization header.
+func (cfg *apiConfig) authenticate(r *http.Request) (auth.AccessToken, error) {
+	if r.Header.Get("Authorization") == "" {
+		return auth.AccessToken{}, errNoCredentials
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return auth.AccessToken{}, err
+	}
+	return auth.ParseAccessToken(r.Context(), token, cfg.jwtKeys)
+}
+
+// respondUnauthorized writes the 401 every authenticated route returns,
+// with the RFC 6750 challenge telling the client whether to log in or to
+// refresh its token.
+func respondUnauthorized(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNoCredentials) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+authRealm+`"`)
+		respondWithProblem(w, &apiError{
+			Status: http.StatusUnauthorized,
+			Code:   codeAuthenticationRequired,
+			Detail: "Authentication required",
+		})
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="`+authRealm+`", error="invalid_token", error_description="The access token is invalid, expired or revoked"`)
+	respondWithProblem(w, &apiError{
+		Status: http.StatusUnauthorized,
+		Code:   codeInvalidToken,
+		Detail: "Access token is invalid, expired or revoked",
+	})
+}
+
+// middlewareAuthenticate rejects requests without a valid access token and
+// makes the caller available to next through requestPrincipal.
+func (cfg *apiConfig) middlewareAuthenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, err := cfg.authenticate(r)
+		if err != nil {
+			respondUnauthorized(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), caller)))
+	})
+}
+
+// middlewareOptionalAuth is middlewareAuthenticate for routes that also
+// serve anonymous callers, who reach next without a principal. A token that
+// is sent but isn't valid is still rejected, so a client with an expired
+// token finds out instead of silently getting the anonymous response.
+func (cfg *apiConfig) middlewareOptionalAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, err := cfg.authenticate(r)
+		if errors.Is(err, errNoCredentials) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err != nil {
+			respondUnauthorized(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), caller)))
+	})
+}
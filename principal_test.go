@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func newTestConfig(t *testing.T) *apiConfig {
+	t.Helper()
+	signer, err := auth.GenerateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := auth.NewKeySet("chirpy", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &apiConfig{jwtKeys: keys}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := newTestConfig(t)
+	userID := uuid.New()
+	sessionID := uuid.New()
+	token, err := auth.MakeSessionJWT(userID, sessionID, auth.RoleModerator, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen auth.AccessToken
+	var authenticated bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, authenticated = principalFromContext(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name          string
+		optional      bool
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{name: "Required, valid token", authorization: "Bearer " + token, wantStatus: http.StatusNoContent},
+		{name: "Required, no token", wantStatus: http.StatusUnauthorized, wantChallenge: `Bearer realm="chirpy"`},
+		{name: "Required, bad token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized, wantChallenge: `error="invalid_token"`},
+		{name: "Required, wrong scheme", authorization: "Basic dXNlcjpwYXNz", wantStatus: http.StatusUnauthorized, wantChallenge: `error="invalid_token"`},
+		{name: "Optional, valid token", optional: true, authorization: "Bearer " + token, wantStatus: http.StatusNoContent},
+		{name: "Optional, no token", optional: true, wantStatus: http.StatusNoContent},
+		{name: "Optional, bad token", optional: true, authorization: "Bearer nope", wantStatus: http.StatusUnauthorized, wantChallenge: `error="invalid_token"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen, authenticated = auth.AccessToken{}, false
+			handler := cfg.middlewareAuthenticate(next)
+			if tt.optional {
+				handler = cfg.middlewareOptionalAuth(next)
+			}
+
+			r := httptest.NewRequest("GET", "/api/chirps", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if !strings.Contains(challenge, tt.wantChallenge) || (tt.wantChallenge == "") != (challenge == "") {
+				t.Errorf("WWW-Authenticate = %q, want it to contain %q", challenge, tt.wantChallenge)
+			}
+
+			wantPrincipal := tt.wantStatus == http.StatusNoContent && tt.authorization != ""
+			if authenticated != wantPrincipal {
+				t.Fatalf("principal present = %v, want %v", authenticated, wantPrincipal)
+			}
+			if wantPrincipal && (seen.UserID != userID || seen.SessionID != sessionID || seen.Role != auth.RoleModerator) {
+				t.Errorf("principal = %+v, want user %s, session %s and the moderator role", seen, userID, sessionID)
+			}
+		})
+	}
+}
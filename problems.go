@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Error codes are part of the API: clients switch on them, so they stay the
+// same even when the detail text changes.
+const (
+	codeBadRequest             = "bad_request"
+	codeMalformedBody          = "malformed_body"
+	codeValidationFailed       = "validation_failed"
+	codeUnauthorized           = "unauthorized"
+	codeAuthenticationRequired = "authentication_required"
+	codeInvalidToken           = "invalid_token"
+	codeForbidden              = "forbidden"
+	codeNotFound               = "not_found"
+	codeConflict               = "conflict"
+	codePayloadTooLarge        = "payload_too_large"
+	codeUnsupportedMediaType   = "unsupported_media_type"
+	codeTooManyRequests        = "too_many_requests"
+	codeInternal               = "internal_error"
+	codeServiceUnavailable     = "service_unavailable"
+)
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            codeBadRequest,
+	http.StatusUnauthorized:          codeUnauthorized,
+	http.StatusForbidden:             codeForbidden,
+	http.StatusNotFound:              codeNotFound,
+	http.StatusConflict:              codeConflict,
+	http.StatusRequestEntityTooLarge: codePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  codeUnsupportedMediaType,
+	http.StatusTooManyRequests:       codeTooManyRequests,
+	http.StatusInternalServerError:   codeInternal,
+	http.StatusServiceUnavailable:    codeServiceUnavailable,
+}
+
+// fieldError is a problem with one field of a request body.
+type fieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// apiError is an error response. Code is a stable identifier for the kind of
+// error, Detail is a human readable explanation of this occurrence and
+// Fields lists the request body fields that failed validation, if any.
+type apiError struct {
+	Status int
+	Code   string
+	Detail string
+	Fields []fieldError
+}
+
+func (e *apiError) Error() string {
+	return e.Detail
+}
+
+// problemDetails is an apiError rendered as an RFC 7807 problem. Error
+// repeats the detail for clients written against the old {"error": msg}
+// responses.
+type problemDetails struct {
+	Type   string       `json:"type"`
+	Title  string       `json:"title"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail,omitempty"`
+	Code   string       `json:"code"`
+	Errors []fieldError `json:"errors,omitempty"`
+	Error  string       `json:"error"`
+}
+
+// malformedBody is the error for a request body that can't be decoded.
+func malformedBody(detail string) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: codeMalformedBody, Detail: detail}
+}
+
+// invalidField is the error for a request body with one field that failed
+// validation.
+func invalidField(field, message string) *apiError {
+	return &apiError{
+		Status: http.StatusBadRequest,
+		Code:   codeValidationFailed,
+		Detail: "The request body failed validation",
+		Fields: []fieldError{{Field: field, Message: message}},
+	}
+}
+
+func respondWithProblem(w http.ResponseWriter, problem *apiError) {
+	code := problem.Code
+	if code == "" {
+		code = statusCodes[problem.Status]
+	}
+	if code == "" {
+		code = codeBadRequest
+		if problem.Status >= 500 {
+			code = codeInternal
+		}
+	}
+
+	dat, err := json.Marshal(problemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(problem.Status),
+		Status: problem.Status,
+		Detail: problem.Detail,
+		Code:   code,
+		Errors: problem.Fields,
+		Error:  problem.Detail,
+	})
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s", err)
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	w.Write(dat)
+}
+
+// respondWithError responds with a problem whose code follows from the
+// status. Use respondWithProblem for errors that need a more specific code.
+func respondWithError(w http.ResponseWriter, code int, msg string) {
+	respondWithProblem(w, &apiError{Status: code, Detail: msg})
+}
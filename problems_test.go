@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) problemDetails {
+	t.Helper()
+	if got := w.Header().Get("Content-Type"); got != problemContentType {
+		t.Errorf("Content-Type = %q, want %q", got, problemContentType)
+	}
+	problem := problemDetails{}
+	err := json.Unmarshal(w.Body.Bytes(), &problem)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v, body %s", err, w.Body)
+	}
+	return problem
+}
+
+func TestRespondWithProblem(t *testing.T) {
+	w := httptest.NewRecorder()
+	respondWithProblem(w, invalidField("body", "Chirp is too long"))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	problem := decodeProblem(t, w)
+	if problem.Status != http.StatusBadRequest || problem.Title != "Bad Request" || problem.Code != codeValidationFailed {
+		t.Errorf("problem = %+v", problem)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "body" {
+		t.Errorf("problem.Errors = %+v, want one error for body", problem.Errors)
+	}
+}
+
+func TestRespondWithErrorDerivesCode(t *testing.T) {
+	tests := []struct {
+		status int
+		want   string
+	}{
+		{http.StatusNotFound, codeNotFound},
+		{http.StatusConflict, codeConflict},
+		{http.StatusInternalServerError, codeInternal},
+		{http.StatusBadGateway, codeInternal},
+		{http.StatusTeapot, codeBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		respondWithError(w, tt.status, "Something failed")
+		problem := decodeProblem(t, w)
+		if problem.Code != tt.want {
+			t.Errorf("code for %d = %q, want %q", tt.status, problem.Code, tt.want)
+		}
+		// Old clients read the message from "error"
+		if problem.Error != "Something failed" || problem.Detail != "Something failed" {
+			t.Errorf("problem = %+v, want the message in detail and error", problem)
+		}
+	}
+}
+
+func TestMalformedBodiesAreBadRequests(t *testing.T) {
+	cfg := &apiConfig{}
+	caller := auth.AccessToken{UserID: uuid.New(), Role: auth.RoleUser}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{name: "Create chirp, not JSON", handler: cfg.createChirpHandler, body: "body=hello"},
+		{name: "Create chirp, empty", handler: cfg.createChirpHandler, body: ""},
+		{name: "Login, truncated", handler: cfg.loginUserHandler, body: `{"email": "walt@`},
+		{name: "Create user, wrong type", handler: cfg.createUserHandler, body: `{"email": 42}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/chirps", strings.NewReader(tt.body))
+			r = r.WithContext(withPrincipal(r.Context(), caller))
+			w := httptest.NewRecorder()
+			tt.handler(w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if problem := decodeProblem(t, w); problem.Code != codeMalformedBody {
+				t.Errorf("code = %q, want %q", problem.Code, codeMalformedBody)
+			}
+		})
+	}
+}
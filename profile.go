@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+)
+
+// userPatch is the body of PATCH /api/users. Fields left out aren't changed.
+// Changing the email or password needs the current password as well.
+type userPatch struct {
+	Email           *string `json:"email" validate:"email"`
+	Password        *string `json:"password" validate:"password"`
+	CurrentPassword string  `json:"current_password"`
+	Username        *string `json:"username" validate:"username"`
+	DisplayName     *string `json:"display_name" validate:"max=50"`
+	Bio             *string `json:"bio" validate:"max=160"`
+}
+
+func optionalString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// emailInUse reports whether another account already uses email.
+func (cfg *apiConfig) emailInUse(ctx context.Context, email string) (bool, error) {
+	_, err := cfg.db.GetUserUsingEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (cfg *apiConfig) patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	accessToken := requestPrincipal(r)
+
+	patch := userPatch{}
+	if !decodeRequest(w, r, &patch) {
+		return
+	}
+
+	currentUser, err := cfg.db.GetUserByID(r.Context(), accessToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	params := database.UpdateUserProfileParams{ID: currentUser.ID}
+
+	if patch.Email != nil || patch.Password != nil {
+		match, _ := auth.CheckPasswordHash(patch.CurrentPassword, currentUser.HashedPassword)
+		if !match {
+			respondWithError(w, http.StatusForbidden, "current_password is required to change the email or password")
+			return
+		}
+	}
+
+	if patch.Password != nil {
+		hashedPass, err := auth.HashPassword(*patch.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Error hashing the password passed in")
+			return
+		}
+		params.HashedPassword = sql.NullString{String: hashedPass, Valid: true}
+	}
+
+	// Like PUT, a new address waits in pending_email until it's verified.
+	// Sending the current address again cancels a pending change.
+	if patch.Email != nil {
+		params.ChangePendingEmail = true
+		if *patch.Email != currentUser.Email {
+			inUse, err := cfg.emailInUse(r.Context(), *patch.Email)
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't check whether the email is in use")
+				return
+			}
+			if inUse {
+				respondWithError(w, http.StatusConflict, "Email is already in use")
+				return
+			}
+			params.PendingEmail = sql.NullString{String: *patch.Email, Valid: true}
+		}
+	}
+
+	if patch.Username != nil {
+		username, _ := normalizeUsername(*patch.Username)
+		params.Username = sql.NullString{String: username, Valid: true}
+	}
+
+	params.DisplayName = optionalString(patch.DisplayName)
+	params.Bio = optionalString(patch.Bio)
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update the user")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	updated, err := qtx.UpdateUserProfile(r.Context(), params)
+	if respondToUserConflict(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update the user")
+		return
+	}
+
+	// A new password logs out every other device, in case the old one leaked
+	if params.HashedPassword.Valid {
+		err = qtx.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+			UserID:   currentUser.ID,
+			FamilyID: accessToken.SessionID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the user's other sessions")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't update the user")
+		return
+	}
+
+	if params.PendingEmail.Valid && params.PendingEmail != currentUser.PendingEmail {
+		err = cfg.sendVerificationEmail(currentUser.ID, params.PendingEmail.String)
+		if err != nil {
+			log.Printf("Error sending the verification email: %v", err)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, userResponseFromDB(updated))
+}
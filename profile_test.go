@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUserPatchOnlySetsPresentFields(t *testing.T) {
+	patch := userPatch{}
+	err := json.Unmarshal([]byte(`{"bio": "", "display_name": "Walter"}`), &patch)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if patch.Email != nil || patch.Password != nil || patch.Username != nil {
+		t.Error("Expected fields left out of the body to stay nil")
+	}
+
+	// An empty bio clears it, which is different from leaving it out
+	bio := optionalString(patch.Bio)
+	if !bio.Valid || bio.String != "" {
+		t.Errorf("optionalString(bio) = %+v, want a valid empty string", bio)
+	}
+	if displayName := optionalString(patch.DisplayName); displayName.String != "Walter" {
+		t.Errorf("optionalString(display_name) = %+v, want Walter", displayName)
+	}
+	if email := optionalString(patch.Email); email.Valid {
+		t.Errorf("optionalString(email) = %+v, want invalid", email)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+type roleChange struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// middlewareRequireRole authenticates the caller and only lets them through
+// to next if their access token carries role, or a role above it. The role
+// comes from the token, so a demoted user keeps their old role until their
+// access token expires, except on admin routes, where the role stored for
+// the user is checked as well.
+func (cfg *apiConfig) middlewareRequireRole(role auth.Role, next http.Handler) http.Handler {
+	return cfg.middlewareAuthenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller := requestPrincipal(r)
+		if !caller.Role.Includes(role) {
+			respondWithError(w, http.StatusForbidden, "This requires the "+string(role)+" role")
+			return
+		}
+
+		if role == auth.RoleAdmin {
+			user, err := cfg.db.GetUserByID(r.Context(), caller.UserID)
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+				return
+			}
+			if err != nil {
+				respondWithError(w, http.StatusInternalServerError, "Couldn't check the user's role")
+				return
+			}
+			if !auth.Role(user.Role).Includes(role) {
+				respondWithError(w, http.StatusForbidden, "This requires the "+string(role)+" role")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	}))
+}
+
+// setUserRoleHandler lets an admin change another user's role. Admins can't
+// change their own, so the last admin can't lock everyone out by accident.
+func (cfg *apiConfig) setUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	if userID == caller.UserID {
+		respondWithError(w, http.StatusForbidden, "Admins can't change their own role")
+		return
+	}
+
+	params := roleChange{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+	role, err := auth.ParseRole(params.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Role must be user, moderator or admin")
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't change the user's role")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	previous, err := qtx.GetUserByID(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User doesn't exist")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't change the user's role")
+		return
+	}
+
+	user, err := qtx.SetUserRole(r.Context(), database.SetUserRoleParams{
+		ID:   userID,
+		Role: string(role),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't change the user's role")
+		return
+	}
+
+	// A role change ends the user's sessions, so they log in again under the
+	// new role
+	if previous.Role != user.Role {
+		err = qtx.RevokeUserRefreshTokens(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the user's sessions")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't change the user's role")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, userResponseFromDB(user))
+}
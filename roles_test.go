@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestMiddlewareRequireRole(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	handler := cfg.middlewareRequireRole(auth.RoleModerator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tokenFor := func(role auth.Role) string {
+		token, err := auth.MakeSessionJWT(uuid.New(), uuid.New(), role, cfg.jwtKeys, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		want          int
+	}{
+		{name: "No token", authorization: "", want: http.StatusUnauthorized},
+		{name: "Garbage token", authorization: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "User", authorization: tokenFor(auth.RoleUser), want: http.StatusForbidden},
+		{name: "Moderator", authorization: tokenFor(auth.RoleModerator), want: http.StatusNoContent},
+		{name: "Admin", authorization: tokenFor(auth.RoleAdmin), want: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/admin/metrics", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareRequireRoleChecksStoredAdminRole(t *testing.T) {
+	tests := []struct {
+		name       string
+		storedRole auth.Role
+		want       int
+	}{
+		{name: "Still an admin", storedRole: auth.RoleAdmin, want: http.StatusNoContent},
+		// The token still says admin, but the user was demoted since
+		{name: "Demoted", storedRole: auth.RoleModerator, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			user := database.User{ID: uuid.New(), Email: "walt@example.com", Role: string(tt.storedRole)}
+			useFakeDB(t, cfg, map[string]fakeQuery{
+				"GetUserByID": rows(userRow(user)),
+			})
+
+			handler := cfg.middlewareRequireRole(auth.RoleAdmin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			token, err := auth.MakeSessionJWT(user.ID, uuid.New(), auth.RoleAdmin, cfg.jwtKeys, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := httptest.NewRequest("GET", "/admin/metrics", nil)
+			r.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetUserRoleHandler(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        auth.Role
+		to          auth.Role
+		wantRevoked bool
+	}{
+		{name: "Role changed", from: auth.RoleAdmin, to: auth.RoleUser, wantRevoked: true},
+		{name: "Same role", from: auth.RoleModerator, to: auth.RoleModerator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			user := database.User{ID: uuid.New(), Email: "walt@example.com", Role: string(tt.from)}
+			updated := user
+			updated.Role = string(tt.to)
+			db := useFakeDB(t, cfg, map[string]fakeQuery{
+				"GetUserByID":             rows(userRow(user)),
+				"SetUserRole":             rows(userRow(updated)),
+				"RevokeUserRefreshTokens": affected(1),
+			})
+
+			r := httptest.NewRequest("PUT", "/admin/users/"+user.ID.String()+"/role", strings.NewReader(`{"role": "`+string(tt.to)+`"}`))
+			r.SetPathValue("userID", user.ID.String())
+			r = r.WithContext(withPrincipal(r.Context(), auth.AccessToken{UserID: uuid.New(), Role: auth.RoleAdmin}))
+			w := httptest.NewRecorder()
+			cfg.setUserRoleHandler(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+			}
+			if db.Ran("RevokeUserRefreshTokens") != tt.wantRevoked {
+				t.Errorf("RevokeUserRefreshTokens ran = %v, want %v", !tt.wantRevoked, tt.wantRevoked)
+			}
+			if !db.Ran("COMMIT") {
+				t.Error("Expected the role change to be committed")
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxSearchQueryLen is counted in characters, like chirp bodies.
+const maxSearchQueryLen = 200
+
+// SearchPage is the envelope returned by chirp search. Results are ordered
+// by rank rather than by a column a cursor could point into, so the next
+// page is asked for by offset. NextOffset is null once the last page has
+// been reached.
+type SearchPage struct {
+	Chirps     []ChirpResponse `json:"chirps"`
+	NextOffset *int64          `json:"next_offset"`
+}
+
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	searchTerms := strings.TrimSpace(query.Get("q"))
+	if searchTerms == "" {
+		respondWithError(w, http.StatusBadRequest, "Search query q is required")
+		return
+	}
+	if utf8.RuneCountInString(searchTerms) > maxSearchQueryLen {
+		respondWithError(w, http.StatusBadRequest, "Search query is too long")
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	if authorIDString := query.Get("author_id"); authorIDString != "" {
+		parsedID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: parsedID, Valid: true}
+	}
+
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	offset := int64(0)
+	if offsetString := query.Get("offset"); offsetString != "" {
+		parsedOffset, err := strconv.ParseInt(offsetString, 10, 32)
+		if err != nil || parsedOffset < 0 {
+			respondWithError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		offset = parsedOffset
+	}
+
+	// One extra row tells whether there's another page, like fetchLimit
+	results, err := cfg.db.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:     searchTerms,
+		AuthorID:  authorID,
+		RowLimit:  limit + 1,
+		RowOffset: int32(offset),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps")
+		return
+	}
+
+	page := SearchPage{Chirps: []ChirpResponse{}}
+	if len(results) > int(limit) {
+		results = results[:limit]
+		next := offset + int64(limit)
+		page.NextOffset = &next
+	}
+	for _, result := range results {
+		page.Chirps = append(page.Chirps, ChirpResponse{
+			ID:           result.ID,
+			CreatedAt:    result.CreatedAt,
+			UpdatedAt:    result.UpdatedAt,
+			Body:         result.Body,
+			UserID:       result.UserID,
+			InReplyTo:    nullUUIDPtr(result.InReplyTo),
+			LikeCount:    result.LikeCount,
+			RechirpCount: result.RechirpCount,
+		})
+	}
+
+	err = cfg.hydrateChirps(r, chirpPointers(page.Chirps))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, page)
+}
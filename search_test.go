@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSearchChirpsHandler(t *testing.T) {
+	cfg := newTestConfig(t)
+
+	var searched []driver.Value
+	searchResult := func(body string) []driver.Value {
+		now := time.Now()
+		return []driver.Value{uuid.NewString(), now, now, body, uuid.NewString(), nil, int64(0), int64(0), float64(0.5)}
+	}
+	db := useFakeDB(t, cfg, map[string]fakeQuery{
+		"SearchChirps": func(args []driver.Value) (fakeResult, error) {
+			searched = args
+			return rows(searchResult("first"), searchResult("second"), searchResult("third"))(args)
+		},
+		"ListMediaForChirps": rows(),
+	})
+
+	search := func(query string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/chirps/search?"+query, nil)
+		w := httptest.NewRecorder()
+		cfg.searchChirpsHandler(w, r)
+		return w
+	}
+
+	w := search("q=" + url.QueryEscape("sharbert") + "&limit=2&offset=4")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d, body %s", w.Code, http.StatusOK, w.Body)
+	}
+	page := SearchPage{}
+	err := json.Unmarshal(w.Body.Bytes(), &page)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if len(page.Chirps) != 2 || page.Chirps[1].Body != "second" {
+		t.Errorf("chirps = %+v, want the first two results", page.Chirps)
+	}
+	if page.NextOffset == nil || *page.NextOffset != 6 {
+		t.Errorf("next_offset = %v, want 6", page.NextOffset)
+	}
+	// One more row than the limit is fetched to tell whether there's a next
+	// page
+	if searched[2] != int64(3) || searched[3] != int64(4) {
+		t.Errorf("searched with limit %v and offset %v, want 3 and 4", searched[2], searched[3])
+	}
+
+	// The length is counted in characters, so 200 accented letters fit
+	if w := search("q=" + url.QueryEscape(strings.Repeat("é", maxSearchQueryLen))); w.Code != http.StatusOK {
+		t.Errorf("status = %d for %d two-byte characters, want %d", w.Code, maxSearchQueryLen, http.StatusOK)
+	}
+	if w := search("q=" + url.QueryEscape(strings.Repeat("é", maxSearchQueryLen+1))); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d for a query that's too long, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	w = search("q=sharbert&limit=0")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d for limit=0, want %d", w.Code, http.StatusBadRequest)
+	}
+	if problem := decodeProblem(t, w); problem.Detail != "limit must be a positive integer" {
+		t.Errorf("detail = %q, want the shared limit error", problem.Detail)
+	}
+
+	if !db.Ran("ListMediaForChirps") {
+		t.Error("Expected the results to be hydrated with their media")
+	}
+}
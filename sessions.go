@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const maxUserAgentLen = 512
+
+// SessionResponse describes one logged in device. A session is a refresh
+// token family, so its ID stays the same across refreshes.
+type SessionResponse struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	Current    bool      `json:"current"`
+}
+
+// clientIP returns the address the request came from. Forwarding headers
+// are ignored since they can be set by anyone when there's no proxy in front.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func clientUserAgent(r *http.Request) string {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgentLen {
+		userAgent = userAgent[:maxUserAgentLen]
+	}
+	return userAgent
+}
+
+func (cfg *apiConfig) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	dbSessions, err := cfg.db.ListActiveSessions(r.Context(), caller.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the user's sessions")
+		return
+	}
+
+	sessions := []SessionResponse{}
+	for _, session := range dbSessions {
+		sessions = append(sessions, SessionResponse{
+			ID:         session.FamilyID,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IpAddress,
+			Current:    session.FamilyID == caller.SessionID,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, sessions)
+}
+
+func (cfg *apiConfig) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid session ID")
+		return
+	}
+
+	revoked, err := cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+		UserID:   caller.UserID,
+		FamilyID: sessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the session")
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "Session doesn't exist or was already revoked")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeOtherSessionsHandler logs out every device except the one making the
+// request. Access tokens issued before sessions existed carry no session, so
+// for those every session is revoked.
+func (cfg *apiConfig) revokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	err := cfg.db.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+		UserID:   caller.UserID,
+		FamilyID: caller.SessionID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the other sessions")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutHandler ends the caller's session: the access token is denylisted
+// until it expires and the session's refresh token is revoked.
+func (cfg *apiConfig) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	caller := requestPrincipal(r)
+
+	err := cfg.jwtKeys.Denylist().Deny(r.Context(), caller.ID, caller.ExpiresAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the access token")
+		return
+	}
+
+	if caller.SessionID != uuid.Nil {
+		_, err = cfg.db.RevokeSession(r.Context(), database.RevokeSessionParams{
+			UserID:   caller.UserID,
+			FamilyID: caller.SessionID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the refresh token")
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
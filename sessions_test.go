@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		want       string
+	}{
+		{name: "IPv4 with port", remoteAddr: "203.0.113.7:51234", want: "203.0.113.7"},
+		{name: "IPv6 with port", remoteAddr: "[2001:db8::1]:443", want: "2001:db8::1"},
+		{name: "No port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			r.Header.Set("X-Forwarded-For", "198.51.100.1")
+			if got := clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientUserAgentTruncates(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/login", nil)
+	r.Header.Set("User-Agent", strings.Repeat("a", maxUserAgentLen+10))
+	if got := clientUserAgent(r); len(got) != maxUserAgentLen {
+		t.Errorf("clientUserAgent() length = %d, want %d", len(got), maxUserAgentLen)
+	}
+}
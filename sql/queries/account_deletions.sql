@@ -0,0 +1,18 @@
+-- name: ScheduleAccountDeletion :one
+-- Asking again doesn't push the purge date back.
+INSERT INTO account_deletions (user_id, requested_at, purge_after)
+VALUES ($1, NOW(), $2)
+ON CONFLICT (user_id) DO UPDATE
+SET purge_after = account_deletions.purge_after
+RETURNING *;
+
+-- name: CancelAccountDeletion :execrows
+DELETE FROM account_deletions
+WHERE user_id = $1;
+
+-- name: ListDueAccountDeletions :many
+SELECT user_id
+FROM account_deletions
+WHERE purge_after <= NOW()
+ORDER BY purge_after ASC
+LIMIT $1;
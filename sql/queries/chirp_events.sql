@@ -0,0 +1,32 @@
+-- name: LockChirpEvents :exec
+-- Held until the transaction ends, so events get their IDs in commit order.
+SELECT pg_advisory_xact_lock(hashtext('chirp_events'));
+
+-- name: CreateChirpEvent :one
+INSERT INTO chirp_events (event_type, chirp_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW()
+)
+RETURNING *;
+
+-- name: NotifyChirpEvent :exec
+SELECT pg_notify('chirp_events', sqlc.arg('payload')::text);
+
+-- name: GetChirpEvent :one
+SELECT *
+FROM chirp_events
+WHERE id = $1;
+
+-- name: ListChirpEventsAfter :many
+SELECT *
+FROM chirp_events
+WHERE id > $1
+ORDER BY id ASC
+LIMIT $2;
+
+-- name: DeleteChirpEventsBefore :exec
+DELETE FROM chirp_events
+WHERE created_at < $1;
@@ -1,11 +1,12 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -21,4 +22,168 @@ WHERE $1 = id;
 
 -- name: DeleteChirpsByID :exec
 DELETE FROM chirps
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListChirpsAsc :many
+SELECT *
+FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListChirpsDesc :many
+SELECT *
+FROM chirps
+WHERE (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit');
+
+
+-- name: ListTimelineAsc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN follows
+ON chirps.user_id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListTimelineDesc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN follows
+ON chirps.user_id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: SearchChirps :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count,
+    ts_rank(search_vector, websearch_to_tsquery('english', sqlc.arg('query'))) AS rank
+FROM chirps
+WHERE search_vector @@ websearch_to_tsquery('english', sqlc.arg('query'))
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id')::uuid)
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg('row_limit')
+OFFSET sqlc.arg('row_offset');
+
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.created_at, parent.updated_at, parent.body, parent.user_id, parent.in_reply_to, parent.like_count, parent.rechirp_count, 1 AS depth
+    FROM chirps parent
+    WHERE parent.id = (SELECT child.in_reply_to FROM chirps child WHERE child.id = $1)
+    UNION ALL
+    SELECT parent.id, parent.created_at, parent.updated_at, parent.body, parent.user_id, parent.in_reply_to, parent.like_count, parent.rechirp_count, ancestors.depth + 1
+    FROM chirps parent
+    INNER JOIN ancestors
+    ON parent.id = ancestors.in_reply_to
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count, depth
+FROM ancestors
+ORDER BY depth DESC;
+
+-- name: GetChirpDescendants :many
+WITH RECURSIVE descendants AS (
+    SELECT child.id, child.created_at, child.updated_at, child.body, child.user_id, child.in_reply_to, child.like_count, child.rechirp_count, 1 AS depth
+    FROM chirps child
+    WHERE child.in_reply_to = sqlc.arg('root_id')
+    UNION ALL
+    SELECT child.id, child.created_at, child.updated_at, child.body, child.user_id, child.in_reply_to, child.like_count, child.rechirp_count, descendants.depth + 1
+    FROM chirps child
+    INNER JOIN descendants
+    ON child.in_reply_to = descendants.id
+    WHERE descendants.depth < sqlc.arg('max_depth')::int
+)
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, like_count, rechirp_count, depth
+FROM descendants
+ORDER BY depth ASC, created_at ASC, id ASC;
+
+-- name: GetChirpForEdit :one
+-- Editable is worked out here rather than in Go, since created_at has no
+-- time zone and only means something next to the database's own NOW().
+SELECT sqlc.embed(chirps),
+    chirps.created_at > NOW() - make_interval(secs => sqlc.arg('edit_window_seconds')::float8) AS editable
+FROM chirps
+WHERE id = sqlc.arg('id')
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+
+-- name: ListTagChirpsAsc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN chirp_hashtags
+ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.tag = sqlc.arg('tag')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListTagChirpsDesc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN chirp_hashtags
+ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.tag = sqlc.arg('tag')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListMentionChirpsAsc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN chirp_mentions
+ON chirps.id = chirp_mentions.chirp_id
+WHERE chirp_mentions.user_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at ASC, chirps.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListMentionChirpsDesc :many
+SELECT chirps.*
+FROM chirps
+INNER JOIN chirp_mentions
+ON chirps.id = chirp_mentions.chirp_id
+WHERE chirp_mentions.user_id = sqlc.arg('user_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('row_limit');
+-- name: ListUserChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,70 @@
+-- Likes and rechirps keep a counter on chirps in step with the join table.
+-- Each statement only touches the counter when a row was actually inserted
+-- or deleted, so repeated and concurrent requests can't drift the count.
+
+-- name: LikeChirp :exec
+WITH inserted AS (
+    INSERT INTO chirp_likes (user_id, chirp_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count + 1
+WHERE id IN (SELECT chirp_id FROM inserted);
+
+-- name: UnlikeChirp :exec
+WITH deleted AS (
+    DELETE FROM chirp_likes
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id IN (SELECT chirp_id FROM deleted);
+
+-- name: Rechirp :exec
+WITH inserted AS (
+    INSERT INTO rechirps (user_id, chirp_id, created_at)
+    VALUES ($1, $2, NOW())
+    ON CONFLICT (user_id, chirp_id) DO NOTHING
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count + 1
+WHERE id IN (SELECT chirp_id FROM inserted);
+
+-- name: UndoRechirp :exec
+WITH deleted AS (
+    DELETE FROM rechirps
+    WHERE user_id = $1 AND chirp_id = $2
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET rechirp_count = rechirp_count - 1
+WHERE id IN (SELECT chirp_id FROM deleted);
+
+-- name: ListLikedChirpIDs :many
+SELECT chirp_id
+FROM chirp_likes
+WHERE user_id = sqlc.arg('user_id')
+AND chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[]);
+
+-- name: ListUserLikes :many
+SELECT chirp_id, created_at
+FROM chirp_likes
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- The counters aren't kept in step by the cascade when a user is deleted, so
+-- their likes and rechirps are taken off first.
+
+-- name: RemoveUserLikeCounts :exec
+UPDATE chirps
+SET like_count = like_count - 1
+WHERE id IN (SELECT chirp_id FROM chirp_likes WHERE user_id = $1);
+
+-- name: RemoveUserRechirpCounts :exec
+UPDATE chirps
+SET rechirp_count = rechirp_count - 1
+WHERE id IN (SELECT chirp_id FROM rechirps WHERE user_id = $1);
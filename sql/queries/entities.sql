@@ -0,0 +1,19 @@
+-- name: AddChirpHashtags :exec
+INSERT INTO chirp_hashtags (chirp_id, tag)
+SELECT sqlc.arg('chirp_id'), unnest(sqlc.arg('tags')::text[])
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: AddChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT sqlc.arg('chirp_id'), users.id
+FROM users
+WHERE users.username = ANY(sqlc.arg('usernames')::text[])
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: DeleteChirpMentions :exec
+DELETE FROM chirp_mentions
+WHERE chirp_id = $1;
\ No newline at end of file
@@ -0,0 +1,69 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (follower_id, followee_id) DO NOTHING;
+
+-- name: UnfollowUser :exec
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: ListFollowingIDs :many
+SELECT followee_id
+FROM follows
+WHERE follower_id = $1;
+
+-- name: ListFollowersAsc :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM users
+INNER JOIN follows
+ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('followee_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, users.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY follows.created_at ASC, users.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowersDesc :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM users
+INNER JOIN follows
+ON users.id = follows.follower_id
+WHERE follows.followee_id = sqlc.arg('followee_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, users.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY follows.created_at DESC, users.id DESC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowingAsc :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM users
+INNER JOIN follows
+ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, users.id) > (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY follows.created_at ASC, users.id ASC
+LIMIT sqlc.arg('row_limit');
+
+-- name: ListFollowingDesc :many
+SELECT sqlc.embed(users), follows.created_at AS followed_at
+FROM users
+INNER JOIN follows
+ON users.id = follows.followee_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (follows.created_at, users.id) < (sqlc.narg('cursor_created_at')::timestamp, sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY follows.created_at DESC, users.id DESC
+LIMIT sqlc.arg('row_limit');
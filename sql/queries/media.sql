@@ -0,0 +1,40 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, content_type, storage_key, thumbnail_key, width, height)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: AttachMediaToChirp :execrows
+UPDATE media
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL;
+
+-- name: ListMediaForChirps :many
+SELECT *
+FROM media
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: ListUserMediaKeys :many
+SELECT storage_key, thumbnail_key
+FROM media
+WHERE user_id = $1;
+
+-- name: DeleteChirpMedia :many
+DELETE FROM media
+WHERE chirp_id = $1
+RETURNING storage_key, thumbnail_key;
+
+-- name: DeleteUnattachedMediaBefore :many
+-- Uploads that were never attached to a chirp.
+DELETE FROM media
+WHERE chirp_id IS NULL AND created_at < $1
+RETURNING storage_key, thumbnail_key;
@@ -0,0 +1,40 @@
+-- name: ListModerationRules :many
+SELECT * FROM moderation_rules
+ORDER BY term ASC;
+
+-- name: UpsertModerationRule :one
+INSERT INTO moderation_rules (term, action, created_at, updated_at)
+VALUES ($1, $2, NOW(), NOW())
+ON CONFLICT (term) DO UPDATE
+SET action = EXCLUDED.action, updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteModerationRule :execrows
+DELETE FROM moderation_rules
+WHERE term = $1;
+
+-- name: FlagChirp :exec
+INSERT INTO chirp_flags (chirp_id, term, flagged_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (chirp_id, term) DO NOTHING;
+
+-- name: ListChirpFlags :many
+-- Oldest first, so the chirps that have waited longest are reviewed first.
+-- The limit counts chirps rather than flags, so a chirp on the page comes
+-- with every term it was flagged for.
+WITH page AS (
+    SELECT chirp_id, MIN(flagged_at)::timestamp AS first_flagged_at
+    FROM chirp_flags
+    GROUP BY chirp_id
+    ORDER BY first_flagged_at ASC, chirp_id ASC
+    LIMIT $1
+)
+SELECT chirp_flags.chirp_id, chirp_flags.term, page.first_flagged_at, chirps.user_id, chirps.body
+FROM page
+JOIN chirp_flags ON chirp_flags.chirp_id = page.chirp_id
+JOIN chirps ON chirps.id = chirp_flags.chirp_id
+ORDER BY page.first_flagged_at ASC, page.chirp_id ASC, chirp_flags.term ASC;
+
+-- name: DismissChirpFlags :execrows
+DELETE FROM chirp_flags
+WHERE chirp_id = $1;
@@ -0,0 +1,26 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3);
+
+-- name: UsePasswordResetToken :one
+UPDATE password_reset_tokens
+SET used_at = NOW()
+WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+RETURNING user_id;
+
+-- name: DeletePasswordResetTokens :exec
+DELETE FROM password_reset_tokens
+WHERE user_id = $1 OR expires_at <= NOW();
+
+-- name: LockPasswordResets :exec
+-- Held until the transaction ends, so concurrent reset requests for the same
+-- user take turns.
+SELECT pg_advisory_xact_lock(hashtext('password_reset_tokens'), hashtext(sqlc.arg('user_id')::text));
+
+-- name: HasRecentPasswordResetToken :one
+SELECT EXISTS (
+    SELECT 1
+    FROM password_reset_tokens
+    WHERE user_id = sqlc.arg('user_id')
+    AND created_at > NOW() - make_interval(secs => sqlc.arg('cooldown_seconds')::float8)
+);
@@ -1,12 +1,16 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, family_id, user_agent, ip_address, last_used_at)
 VALUES(
     $1,
     NOW(),
     NOW(),
     $2,
     $3,
-    NULL
+    NULL,
+    $4,
+    $5,
+    $6,
+    NOW()
 )
 RETURNING *;
 
@@ -20,4 +24,52 @@ RETURNING *;
 SELECT users.* FROM users
 INNER JOIN refresh_tokens
 ON users.id = refresh_tokens.user_id
-WHERE token = $1 AND revoked_at IS NULL AND expires_at > NOW();
\ No newline at end of file
+WHERE token = $1 AND revoked_at IS NULL AND expires_at > NOW();
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT *
+FROM refresh_tokens
+WHERE token = $1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenReplaced :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW(), replaced_by = $2
+WHERE token = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = COALESCE(revoked_at, NOW())
+WHERE family_id = $1;
+
+-- name: ListActiveSessions :many
+SELECT
+    refresh_tokens.family_id,
+    (
+        SELECT MIN(started.created_at)
+        FROM refresh_tokens AS started
+        WHERE started.family_id = refresh_tokens.family_id
+    )::timestamp AS created_at,
+    refresh_tokens.last_used_at,
+    refresh_tokens.user_agent,
+    refresh_tokens.ip_address
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+    AND refresh_tokens.revoked_at IS NULL
+    AND refresh_tokens.expires_at > NOW()
+ORDER BY refresh_tokens.last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND family_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(), revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -0,0 +1,15 @@
+-- name: DenyAccessToken :exec
+INSERT INTO revoked_access_tokens (jti, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: IsAccessTokenDenied :one
+SELECT EXISTS (
+    SELECT 1
+    FROM revoked_access_tokens
+    WHERE jti = $1 AND expires_at > NOW()
+);
+
+-- name: DeleteExpiredAccessTokenDenials :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at <= NOW();
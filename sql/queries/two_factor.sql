@@ -0,0 +1,78 @@
+-- name: CreateTotpCredential :one
+INSERT INTO totp_credentials (user_id, secret, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO UPDATE
+SET secret = EXCLUDED.secret, created_at = NOW(), last_used_step = 0
+WHERE totp_credentials.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetTotpCredential :one
+SELECT *
+FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: ConfirmTotpCredential :exec
+UPDATE totp_credentials
+SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1;
+
+-- name: UseTotpStep :execrows
+UPDATE totp_credentials
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2;
+
+-- name: DeleteTotpCredential :exec
+DELETE FROM totp_credentials
+WHERE user_id = $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg('user_id'), unnest(sqlc.arg('code_hashes')::text[]), NOW();
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = NOW()
+WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, created_at, expires_at)
+VALUES ($1, $2, NOW(), $3);
+
+-- name: GetLoginChallengeForUpdate :one
+SELECT *
+FROM login_challenges
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: RecordLoginChallengeFailure :exec
+UPDATE login_challenges
+SET attempts = attempts + 1
+WHERE token_hash = $1;
+
+-- name: DeleteLoginChallenge :exec
+DELETE FROM login_challenges
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredLoginChallenges :exec
+DELETE FROM login_challenges
+WHERE expires_at <= NOW();
+
+-- name: GetTotpCredentialForUpdate :one
+SELECT *
+FROM totp_credentials
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: RecordTotpFailure :exec
+UPDATE totp_credentials
+SET failed_attempts = $2, locked_until = $3
+WHERE user_id = $1;
+
+-- name: ResetTotpFailures :exec
+UPDATE totp_credentials
+SET failed_attempts = 0, locked_until = NULL
+WHERE user_id = $1;
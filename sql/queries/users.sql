@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -17,9 +18,14 @@ SELECT *
 FROM users
 WHERE $1 = email;
 
+-- name: GetUserByID :one
+SELECT *
+FROM users
+WHERE id = $1;
+
 -- name: UpdateUserPassEmail :one
 UPDATE users
-SET email = $2, hashed_password = $3, updated_at = NOW()
+SET hashed_password = $2, pending_email = $3, username = COALESCE(sqlc.narg('username'), username), updated_at = NOW()
 WHERE id = $1
 RETURNING *;
 
@@ -27,4 +33,40 @@ RETURNING *;
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET email = sqlc.arg('email'),
+    pending_email = CASE WHEN pending_email = sqlc.arg('email') THEN NULL ELSE pending_email END,
+    email_verified_at = NOW(),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id') AND (email = sqlc.arg('email') OR pending_email = sqlc.arg('email'))
+RETURNING *;
+
+-- name: UpdateUserProfile :one
+UPDATE users
+SET hashed_password = COALESCE(sqlc.narg('hashed_password'), hashed_password),
+    pending_email = CASE WHEN sqlc.arg('change_pending_email')::bool THEN sqlc.narg('pending_email') ELSE pending_email END,
+    username = COALESCE(sqlc.narg('username'), username),
+    display_name = COALESCE(sqlc.narg('display_name'), display_name),
+    bio = COALESCE(sqlc.narg('bio'), bio),
+    updated_at = NOW()
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: PurgeUser :execrows
+DELETE FROM users
+WHERE id = $1
+AND id IN (SELECT user_id FROM account_deletions WHERE purge_after <= NOW());
+
+-- name: SetUserRole :one
+UPDATE users
+SET role = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- Deleting a chirp orphans its replies: they keep existing as top level
+-- chirps and their in_reply_to is cleared.
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID NULL
+REFERENCES chirps(id)
+ON DELETE SET NULL;
+
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+
+-- +goose Down
+DROP INDEX chirps_in_reply_to_idx;
+
+ALTER TABLE chirps
+DROP COLUMN in_reply_to;
@@ -0,0 +1,22 @@
+-- +goose Up
+-- Every login starts a token family. Refreshing revokes the presented token
+-- and issues its replacement in the same family, so a token that shows up
+-- again after being replaced means the family has leaked.
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NULL,
+ADD COLUMN replaced_by TEXT NULL;
+
+UPDATE refresh_tokens
+SET family_id = gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id SET NOT NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by,
+DROP COLUMN family_id;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- refresh_tokens.token and replaced_by now hold the hex encoded SHA-256
+-- digest of the token rather than the token itself.
+UPDATE refresh_tokens
+SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    replaced_by = encode(sha256(convert_to(replaced_by, 'UTF8')), 'hex');
+
+-- +goose Down
+-- Digests can't be turned back into tokens, so rolling back revokes every
+-- outstanding refresh token instead.
+UPDATE refresh_tokens
+SET revoked_at = COALESCE(revoked_at, NOW()), updated_at = NOW();
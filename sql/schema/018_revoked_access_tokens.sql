@@ -0,0 +1,12 @@
+-- +goose Up
+-- Access tokens logged out before they expire, by jti. Rows are only needed
+-- until expires_at, after which the token is rejected anyway.
+CREATE TABLE revoked_access_tokens (
+    jti TEXT PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_access_tokens_expires_at_idx ON revoked_access_tokens (expires_at);
+
+-- +goose Down
+DROP TABLE revoked_access_tokens;
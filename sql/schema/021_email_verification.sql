@@ -0,0 +1,15 @@
+-- +goose Up
+-- A changed email address waits in pending_email until it's confirmed, and
+-- the current address keeps working until then. Accounts created before
+-- verification existed are treated as verified.
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP NULL,
+ADD COLUMN pending_email TEXT NULL;
+
+UPDATE users
+SET email_verified_at = created_at;
+
+-- +goose Down
+ALTER TABLE users
+DROP COLUMN pending_email,
+DROP COLUMN email_verified_at;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- A deletion request keeps the account around for a grace period, during
+-- which logging in again cancels it. Afterwards the user row is deleted and
+-- everything that references it goes with it.
+CREATE TABLE account_deletions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    requested_at TIMESTAMP NOT NULL,
+    purge_after TIMESTAMP NOT NULL
+);
+
+CREATE INDEX account_deletions_purge_after_idx ON account_deletions (purge_after);
+
+-- +goose Down
+DROP TABLE account_deletions;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Word list rules added through the admin API. They're applied on top of
+-- the word list file, so a term here overrides the file's action for it.
+CREATE TABLE moderation_rules (
+    term TEXT PRIMARY KEY,
+    action TEXT NOT NULL CHECK (action IN ('mask', 'reject', 'flag')),
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Chirps that matched a flag rule, waiting for a moderator to review them.
+CREATE TABLE chirp_flags (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    term TEXT NOT NULL,
+    flagged_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, term)
+);
+
+CREATE INDEX chirp_flags_flagged_at_idx ON chirp_flags (flagged_at);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE moderation_rules;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Lets the sweep of abandoned uploads find them without scanning every
+-- attached image.
+CREATE INDEX media_unattached_created_at_idx ON media (created_at)
+WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP INDEX media_unattached_created_at_idx;
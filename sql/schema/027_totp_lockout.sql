@@ -0,0 +1,12 @@
+-- +goose Up
+-- Wrong codes are counted across login challenges, since a new challenge
+-- only takes the password. Past a few, logins are locked for a while that
+-- doubles with every further failure.
+ALTER TABLE totp_credentials
+ADD COLUMN failed_attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN locked_until TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE totp_credentials
+DROP COLUMN locked_until,
+DROP COLUMN failed_attempts;
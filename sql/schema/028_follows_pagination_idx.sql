@@ -0,0 +1,11 @@
+-- +goose Up
+-- Keyset pagination of the follower and following listings orders by when
+-- the follow happened, then by the other user's id.
+CREATE INDEX follows_followee_id_created_at_idx ON follows (followee_id, created_at, follower_id);
+CREATE INDEX follows_follower_id_created_at_idx ON follows (follower_id, created_at, followee_id);
+DROP INDEX follows_followee_id_idx;
+
+-- +goose Down
+CREATE INDEX follows_followee_id_idx ON follows (followee_id);
+DROP INDEX follows_follower_id_created_at_idx;
+DROP INDEX follows_followee_id_created_at_idx;
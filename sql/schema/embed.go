@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations so the server binary can apply
+// and verify them without the sql/schema directory being present on disk.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
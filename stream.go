@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	chirpEventsChannel     = "chirp_events"
+	chirpCreatedEvent      = "chirp.created"
+	chirpDeletedEvent      = "chirp.deleted"
+	streamBufferSize       = 64
+	streamReplayLimit      = 500
+	streamHeartbeat        = 25 * time.Second
+	chirpEventRetention    = 24 * time.Hour
+	chirpEventPruneEvery   = time.Hour
+	chirpEventCatchUpLimit = 1000
+)
+
+// streamEvent is a chirp event ready to be written to SSE clients.
+type streamEvent struct {
+	ID     int64
+	Type   string
+	UserID uuid.UUID
+	Data   []byte
+}
+
+// streamFilter decides which events a subscriber receives. A nil followed
+// set means the subscriber didn't ask for followed-only events.
+type streamFilter struct {
+	authorID uuid.NullUUID
+	followed map[uuid.UUID]bool
+}
+
+func (f streamFilter) matches(userID uuid.UUID) bool {
+	if f.authorID.Valid && f.authorID.UUID != userID {
+		return false
+	}
+	if f.followed != nil && !f.followed[userID] {
+		return false
+	}
+	return true
+}
+
+type streamSubscriber struct {
+	filter streamFilter
+	events chan streamEvent
+}
+
+// chirpStream fans events out to every SSE client connected to this
+// instance. Subscribers that fall too far behind are dropped and can resume
+// with Last-Event-ID.
+type chirpStream struct {
+	mu          sync.Mutex
+	subscribers map[*streamSubscriber]struct{}
+	lastID      int64
+}
+
+func newChirpStream() *chirpStream {
+	return &chirpStream{subscribers: map[*streamSubscriber]struct{}{}}
+}
+
+func (s *chirpStream) subscribe(filter streamFilter) *streamSubscriber {
+	sub := &streamSubscriber{
+		filter: filter,
+		events: make(chan streamEvent, streamBufferSize),
+	}
+	s.mu.Lock()
+	s.subscribers[sub] = struct{}{}
+	s.mu.Unlock()
+	return sub
+}
+
+func (s *chirpStream) unsubscribe(sub *streamSubscriber) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.subscribers[sub]; ok {
+		delete(s.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+func (s *chirpStream) broadcast(event streamEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.ID > s.lastID {
+		s.lastID = event.ID
+	}
+	for sub := range s.subscribers {
+		if !sub.filter.matches(event.UserID) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(s.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+func (s *chirpStream) lastEventID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastID
+}
+
+// recordChirpEvent stores a chirp event and notifies every instance about
+// it. Both happen inside the caller's transaction, so the notification is
+// only delivered once the change is committed.
+//
+// Subscribers and Last-Event-ID resumes skip every event at or below the
+// last ID they've seen, which is only safe if IDs are handed out in commit
+// order. A sequence alone doesn't do that, since a transaction can take an
+// ID and commit after one that took a later ID, so writers take a lock that
+// is held until commit first. Callers should commit right after this.
+func recordChirpEvent(ctx context.Context, q *database.Queries, eventType string, chirp database.Chirp) error {
+	err := q.LockChirpEvents(ctx)
+	if err != nil {
+		return err
+	}
+	event, err := q.CreateChirpEvent(ctx, database.CreateChirpEventParams{
+		EventType: eventType,
+		ChirpID:   chirp.ID,
+		UserID:    chirp.UserID,
+	})
+	if err != nil {
+		return err
+	}
+	return q.NotifyChirpEvent(ctx, strconv.FormatInt(event.ID, 10))
+}
+
+// buildStreamEvent renders a stored event. It reports false for events that
+// can't be rendered any more, such as a created chirp that was since deleted.
+func (cfg *apiConfig) buildStreamEvent(ctx context.Context, event database.ChirpEvent) (streamEvent, bool, error) {
+	var payload any
+	switch event.EventType {
+	case chirpCreatedEvent:
+		chirp, err := cfg.db.GetChirpsByID(ctx, event.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return streamEvent{}, false, nil
+		}
+		if err != nil {
+			return streamEvent{}, false, err
+		}
+		response := chirpResponseFromDB(chirp)
+		err = cfg.fillMedia(ctx, []*ChirpResponse{&response})
+		if err != nil {
+			return streamEvent{}, false, err
+		}
+		payload = response
+	case chirpDeletedEvent:
+		payload = struct {
+			ID     uuid.UUID `json:"id"`
+			UserID uuid.UUID `json:"user_id"`
+		}{ID: event.ChirpID, UserID: event.UserID}
+	default:
+		return streamEvent{}, false, nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return streamEvent{}, false, err
+	}
+	return streamEvent{ID: event.ID, Type: event.EventType, UserID: event.UserID, Data: data}, true, nil
+}
+
+// runChirpStream turns notifications from the Postgres listener into
+// broadcasts. pq sends a nil notification after reconnecting, at which point
+// anything missed while disconnected is read back from chirp_events.
+func (cfg *apiConfig) runChirpStream(listener *pq.Listener) {
+	pruneTicker := time.NewTicker(chirpEventPruneEvery)
+	defer pruneTicker.Stop()
+
+	for {
+		select {
+		case notification, ok := <-listener.Notify:
+			if !ok {
+				return
+			}
+			if notification == nil {
+				cfg.catchUpChirpStream()
+				continue
+			}
+
+			eventID, err := strconv.ParseInt(notification.Extra, 10, 64)
+			if err != nil {
+				log.Printf("Ignoring malformed chirp event notification %q", notification.Extra)
+				continue
+			}
+			event, err := cfg.db.GetChirpEvent(context.Background(), eventID)
+			if err != nil {
+				log.Printf("Error loading chirp event %d: %v", eventID, err)
+				continue
+			}
+			cfg.publishChirpEvent(event)
+		case <-pruneTicker.C:
+			err := cfg.db.DeleteChirpEventsBefore(context.Background(), time.Now().Add(-chirpEventRetention))
+			if err != nil {
+				log.Printf("Error pruning chirp events: %v", err)
+			}
+		}
+	}
+}
+
+func (cfg *apiConfig) catchUpChirpStream() {
+	lastID := cfg.stream.lastEventID()
+	if lastID == 0 {
+		return
+	}
+
+	events, err := cfg.db.ListChirpEventsAfter(context.Background(), database.ListChirpEventsAfterParams{
+		ID:    lastID,
+		Limit: chirpEventCatchUpLimit,
+	})
+	if err != nil {
+		log.Printf("Error catching up on chirp events: %v", err)
+		return
+	}
+	for _, event := range events {
+		cfg.publishChirpEvent(event)
+	}
+}
+
+func (cfg *apiConfig) publishChirpEvent(event database.ChirpEvent) {
+	rendered, ok, err := cfg.buildStreamEvent(context.Background(), event)
+	if err != nil {
+		log.Printf("Error rendering chirp event %d: %v", event.ID, err)
+		return
+	}
+	if ok {
+		cfg.stream.broadcast(rendered)
+	}
+}
+
+func writeStreamEvent(w io.Writer, event streamEvent) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+	return err
+}
+
+func (cfg *apiConfig) streamHandler(w http.ResponseWriter, r *http.Request) {
+	filter := streamFilter{}
+
+	if authorIDString := r.URL.Query().Get("author_id"); authorIDString != "" {
+		authorID, err := uuid.Parse(authorIDString)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid author ID")
+			return
+		}
+		filter.authorID = uuid.NullUUID{UUID: authorID, Valid: true}
+	}
+
+	if r.URL.Query().Get("followed") == "true" {
+		caller, ok := principalFromContext(r.Context())
+		if !ok {
+			respondUnauthorized(w, errNoCredentials)
+			return
+		}
+
+		following, err := cfg.db.ListFollowingIDs(r.Context(), caller.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve followed users")
+			return
+		}
+		filter.followed = map[uuid.UUID]bool{}
+		for _, userID := range following {
+			filter.followed[userID] = true
+		}
+	}
+
+	lastEventID := int64(0)
+	if lastEventIDString := r.Header.Get("Last-Event-ID"); lastEventIDString != "" {
+		parsedID, err := strconv.ParseInt(lastEventIDString, 10, 64)
+		if err != nil || parsedID < 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid Last-Event-ID")
+			return
+		}
+		lastEventID = parsedID
+	}
+
+	// Subscribe before replaying so nothing published during the replay is
+	// lost; duplicates are skipped by comparing event IDs.
+	sub := cfg.stream.subscribe(filter)
+	defer cfg.stream.unsubscribe(sub)
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if lastEventID > 0 {
+		missed, err := cfg.db.ListChirpEventsAfter(r.Context(), database.ListChirpEventsAfterParams{
+			ID:    lastEventID,
+			Limit: streamReplayLimit,
+		})
+		if err != nil {
+			log.Printf("Error replaying chirp events: %v", err)
+			return
+		}
+		for _, event := range missed {
+			lastEventID = event.ID
+			if !filter.matches(event.UserID) {
+				continue
+			}
+			rendered, ok, err := cfg.buildStreamEvent(r.Context(), event)
+			if err != nil {
+				log.Printf("Error rendering chirp event %d: %v", event.ID, err)
+				return
+			}
+			if ok && writeStreamEvent(w, rendered) != nil {
+				return
+			}
+		}
+	}
+	if controller.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": ping\n\n")
+			if err != nil || controller.Flush() != nil {
+				return
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if event.ID <= lastEventID {
+				continue
+			}
+			lastEventID = event.ID
+			if writeStreamEvent(w, event) != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestChirpStreamBroadcastFilters(t *testing.T) {
+	author := uuid.New()
+	other := uuid.New()
+
+	stream := newChirpStream()
+	everyone := stream.subscribe(streamFilter{})
+	onlyAuthor := stream.subscribe(streamFilter{authorID: uuid.NullUUID{UUID: author, Valid: true}})
+	onlyFollowed := stream.subscribe(streamFilter{followed: map[uuid.UUID]bool{other: true}})
+
+	stream.broadcast(streamEvent{ID: 1, Type: chirpCreatedEvent, UserID: author})
+
+	if len(everyone.events) != 1 {
+		t.Errorf("unfiltered subscriber got %d events, want 1", len(everyone.events))
+	}
+	if len(onlyAuthor.events) != 1 {
+		t.Errorf("author subscriber got %d events, want 1", len(onlyAuthor.events))
+	}
+	if len(onlyFollowed.events) != 0 {
+		t.Errorf("followed-only subscriber got %d events, want 0", len(onlyFollowed.events))
+	}
+	if stream.lastEventID() != 1 {
+		t.Errorf("lastEventID() = %d, want 1", stream.lastEventID())
+	}
+}
+
+func TestChirpStreamDropsSlowSubscribers(t *testing.T) {
+	stream := newChirpStream()
+	sub := stream.subscribe(streamFilter{})
+
+	for i := 0; i <= streamBufferSize; i++ {
+		stream.broadcast(streamEvent{ID: int64(i + 1), Type: chirpCreatedEvent})
+	}
+
+	received := 0
+	for range sub.events {
+		received++
+	}
+	if received != streamBufferSize {
+		t.Errorf("slow subscriber received %d events before being dropped, want %d", received, streamBufferSize)
+	}
+
+	// Unsubscribing a dropped subscriber must not close its channel twice.
+	stream.unsubscribe(sub)
+}
+
+func TestWriteStreamEvent(t *testing.T) {
+	buf := bytes.Buffer{}
+	err := writeStreamEvent(&buf, streamEvent{ID: 42, Type: chirpDeletedEvent, Data: []byte(`{"id":"x"}`)})
+	if err != nil {
+		t.Fatalf("writeStreamEvent() error = %v", err)
+	}
+
+	want := "id: 42\nevent: chirp.deleted\ndata: {\"id\":\"x\"}\n\n"
+	if buf.String() != want {
+		t.Errorf("writeStreamEvent() wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// maxThreadDepth bounds how many levels of replies are returned below the
+// requested chirp.
+const maxThreadDepth = 50
+
+// ThreadChirp is a chirp inside a conversation. Depth is the number of reply
+// hops between it and the chirp the thread was requested for.
+type ThreadChirp struct {
+	ChirpResponse
+	Depth   int            `json:"depth"`
+	Replies []*ThreadChirp `json:"replies,omitempty"`
+}
+
+type ThreadResponse struct {
+	Chirp       ChirpResponse  `json:"chirp"`
+	Ancestors   []ThreadChirp  `json:"ancestors"`
+	Descendants []*ThreadChirp `json:"descendants"`
+}
+
+func newThreadChirp(chirp ChirpResponse, depth int32) *ThreadChirp {
+	return &ThreadChirp{ChirpResponse: chirp, Depth: int(depth)}
+}
+
+// buildReplyTree nests the flat descendant rows under their parents. Rows
+// must be ordered by depth so every parent is seen before its replies.
+func buildReplyTree(rootID uuid.UUID, rows []database.GetChirpDescendantsRow) []*ThreadChirp {
+	roots := []*ThreadChirp{}
+	nodes := map[uuid.UUID]*ThreadChirp{}
+
+	for _, row := range rows {
+		node := newThreadChirp(ChirpResponse{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyTo:    nullUUIDPtr(row.InReplyTo),
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+		}, row.Depth)
+		nodes[row.ID] = node
+
+		if row.InReplyTo.UUID == rootID {
+			roots = append(roots, node)
+			continue
+		}
+		parent, ok := nodes[row.InReplyTo.UUID]
+		if !ok {
+			continue
+		}
+		parent.Replies = append(parent.Replies, node)
+	}
+
+	return roots
+}
+
+func (cfg *apiConfig) getChirpThreadHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid chirp Id")
+		return
+	}
+
+	chirp, err := cfg.db.GetChirpsByID(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Something went wrong when retrieving the chirp by id")
+		return
+	}
+
+	ancestorRows, err := cfg.db.GetChirpAncestors(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirp's ancestors")
+		return
+	}
+
+	descendantRows, err := cfg.db.GetChirpDescendants(r.Context(), database.GetChirpDescendantsParams{
+		RootID:   chirpID,
+		MaxDepth: maxThreadDepth,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the chirp's replies")
+		return
+	}
+
+	ancestors := []ThreadChirp{}
+	for _, row := range ancestorRows {
+		ancestors = append(ancestors, *newThreadChirp(ChirpResponse{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyTo:    nullUUIDPtr(row.InReplyTo),
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+		}, row.Depth))
+	}
+
+	thread := ThreadResponse{
+		Chirp:       chirpResponseFromDB(chirp),
+		Ancestors:   ancestors,
+		Descendants: buildReplyTree(chirpID, descendantRows),
+	}
+
+	err = cfg.hydrateChirps(r, thread.chirps())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't load the chirps' likes and media")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, thread)
+}
+
+// chirps returns every chirp in the thread so they can be updated in place.
+func (t *ThreadResponse) chirps() []*ChirpResponse {
+	all := []*ChirpResponse{&t.Chirp}
+	for i := range t.Ancestors {
+		all = append(all, &t.Ancestors[i].ChirpResponse)
+	}
+
+	var walk func(nodes []*ThreadChirp)
+	walk = func(nodes []*ThreadChirp) {
+		for _, node := range nodes {
+			all = append(all, &node.ChirpResponse)
+			walk(node.Replies)
+		}
+	}
+	walk(t.Descendants)
+
+	return all
+}
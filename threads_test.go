@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestBuildReplyTree(t *testing.T) {
+	rootID := uuid.New()
+	replyA := uuid.New()
+	replyB := uuid.New()
+	nested := uuid.New()
+
+	rows := []database.GetChirpDescendantsRow{
+		{ID: replyA, InReplyTo: uuid.NullUUID{UUID: rootID, Valid: true}, Depth: 1},
+		{ID: replyB, InReplyTo: uuid.NullUUID{UUID: rootID, Valid: true}, Depth: 1},
+		{ID: nested, InReplyTo: uuid.NullUUID{UUID: replyA, Valid: true}, Depth: 2},
+	}
+
+	tree := buildReplyTree(rootID, rows)
+
+	if len(tree) != 2 {
+		t.Fatalf("expected 2 direct replies, got %d", len(tree))
+	}
+	if tree[0].ID != replyA || tree[1].ID != replyB {
+		t.Errorf("direct replies out of order: got %v and %v", tree[0].ID, tree[1].ID)
+	}
+	if len(tree[0].Replies) != 1 || tree[0].Replies[0].ID != nested {
+		t.Fatalf("expected nested reply under %v, got %+v", replyA, tree[0].Replies)
+	}
+	if tree[0].Replies[0].Depth != 2 {
+		t.Errorf("nested reply depth = %d, want 2", tree[0].Replies[0].Depth)
+	}
+	if len(tree[1].Replies) != 0 {
+		t.Errorf("expected no replies under %v, got %d", replyB, len(tree[1].Replies))
+	}
+}
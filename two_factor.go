@@ -0,0 +1,478 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	totpIssuer                = "Chirpy"
+	recoveryCodeCount         = 10
+	loginChallengeLifetime    = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+	// After totpFreeFailures wrong codes in a row, across every login
+	// challenge and every other place a code is asked for, codes are refused
+	// for totpBaseLockout, doubling with each further failure up to
+	// totpMaxLockout.
+	totpFreeFailures = 5
+	totpBaseLockout  = time.Minute
+	totpMaxLockout   = time.Hour
+)
+
+// totpLockout is how long codes are refused after failures wrong codes in a
+// row.
+func totpLockout(failures int32) time.Duration {
+	if failures < totpFreeFailures {
+		return 0
+	}
+	lockout := totpBaseLockout
+	for range failures - totpFreeFailures {
+		lockout *= 2
+		if lockout >= totpMaxLockout {
+			return totpMaxLockout
+		}
+	}
+	return lockout
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// LoginChallengeResponse is what a password login returns instead of tokens
+// when the user has 2FA enabled.
+type LoginChallengeResponse struct {
+	TwoFactorRequired bool      `json:"two_factor_required"`
+	ChallengeToken    string    `json:"challenge_token"`
+	ExpiresAt         time.Time `json:"expires_at"`
+}
+
+type twoFactorCode struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type twoFactorLogin struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	credential, err := cfg.db.GetTotpCredential(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return credential.ConfirmedAt.Valid, nil
+}
+
+// createLoginChallenge stores a short-lived challenge for a user who got
+// their password right, to be exchanged at /api/login/2fa. Challenge tokens
+// are random like refresh tokens, so they're stored the same way.
+func (cfg *apiConfig) createLoginChallenge(ctx context.Context, userID uuid.UUID) (LoginChallengeResponse, error) {
+	err := cfg.db.DeleteExpiredLoginChallenges(ctx)
+	if err != nil {
+		return LoginChallengeResponse{}, err
+	}
+
+	challengeToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return LoginChallengeResponse{}, err
+	}
+	expiresAt := time.Now().Add(loginChallengeLifetime)
+
+	err = cfg.db.CreateLoginChallenge(ctx, database.CreateLoginChallengeParams{
+		TokenHash: auth.HashRefreshToken(challengeToken),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return LoginChallengeResponse{}, err
+	}
+
+	return LoginChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challengeToken,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+// totpLockedError is returned by checkSecondFactor while the user is locked
+// out for getting too many codes wrong.
+type totpLockedError struct {
+	until time.Time
+}
+
+func (e *totpLockedError) Error() string {
+	return "two-factor codes are locked until " + e.until.Format(time.RFC3339)
+}
+
+// checkSecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are used up by a successful check, which also clears the
+// user's count of wrong codes.
+//
+// credential must have been read with GetTotpCredentialForUpdate in the
+// transaction q belongs to, so concurrent guesses take turns. While the user
+// is locked out it returns a *totpLockedError without looking at code. A
+// wrong code is counted towards the lockout, which only sticks if the caller
+// commits the transaction before answering.
+func checkSecondFactor(ctx context.Context, q *database.Queries, credential database.TotpCredential, code string) (bool, error) {
+	if credential.LockedUntil.Valid && credential.LockedUntil.Time.After(time.Now()) {
+		return false, &totpLockedError{until: credential.LockedUntil.Time}
+	}
+
+	ok, err := useSecondFactor(ctx, q, credential, code)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		return true, q.ResetTotpFailures(ctx, credential.UserID)
+	}
+
+	failures := credential.FailedAttempts + 1
+	lockedUntil := sql.NullTime{}
+	if lockout := totpLockout(failures); lockout > 0 {
+		lockedUntil = sql.NullTime{Time: time.Now().Add(lockout), Valid: true}
+	}
+	return false, q.RecordTotpFailure(ctx, database.RecordTotpFailureParams{
+		UserID:         credential.UserID,
+		FailedAttempts: failures,
+		LockedUntil:    lockedUntil,
+	})
+}
+
+func useSecondFactor(ctx context.Context, q *database.Queries, credential database.TotpCredential, code string) (bool, error) {
+	step, ok := auth.ValidateTOTP(credential.Secret, code, time.Now())
+	if ok {
+		used, err := q.UseTotpStep(ctx, database.UseTotpStepParams{
+			UserID:       credential.UserID,
+			LastUsedStep: step,
+		})
+		if err != nil {
+			return false, err
+		}
+		return used > 0, nil
+	}
+
+	used, err := q.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   credential.UserID,
+		CodeHash: auth.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return false, err
+	}
+	return used > 0, nil
+}
+
+// respondToTOTPLockout writes the 429 for an error from checkSecondFactor
+// that means the user is locked out, and reports whether it did.
+func respondToTOTPLockout(w http.ResponseWriter, err error) bool {
+	locked := &totpLockedError{}
+	if !errors.As(err, &locked) {
+		return false
+	}
+	retryAfter := int(time.Until(locked.until).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	respondWithError(w, http.StatusTooManyRequests, "Too many wrong two-factor codes; try again later")
+	return true
+}
+
+// replaceRecoveryCodes generates a fresh set of recovery codes, stores their
+// digests in place of the old ones and returns the plaintext codes, which
+// are only ever shown once.
+func replaceRecoveryCodes(ctx context.Context, q *database.Queries, userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := auth.GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, auth.HashRecoveryCode(code))
+	}
+
+	err := q.DeleteRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = q.CreateRecoveryCodes(ctx, database.CreateRecoveryCodesParams{
+		UserID:     userID,
+		CodeHashes: hashes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (cfg *apiConfig) enrollTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't generate a two-factor secret")
+		return
+	}
+
+	// Enrolling again before confirming replaces the pending secret, but a
+	// confirmed one has to be disabled first
+	_, err = cfg.db.CreateTotpCredential(r.Context(), database.CreateTotpCredentialParams{
+		UserID: userID,
+		Secret: secret,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save the two-factor secret")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, TwoFactorEnrollResponse{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) confirmTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	params := twoFactorCode{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	credential, err := qtx.GetTotpCredential(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Two-factor enrollment hasn't been started")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the two-factor secret")
+		return
+	}
+	if credential.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	step, ok := auth.ValidateTOTP(credential.Secret, params.Code, time.Now())
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Code doesn't match the authenticator")
+		return
+	}
+
+	err = qtx.ConfirmTotpCredential(r.Context(), database.ConfirmTotpCredentialParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm two-factor authentication")
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(r.Context(), qtx, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create recovery codes")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't confirm two-factor authentication")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func (cfg *apiConfig) disableTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	userID := requestPrincipal(r).UserID
+
+	params := twoFactorCode{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	credential, err := qtx.GetTotpCredentialForUpdate(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !credential.ConfirmedAt.Valid) {
+		respondWithError(w, http.StatusNotFound, "Two-factor authentication isn't enabled")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't retrieve the two-factor secret")
+		return
+	}
+
+	// A stolen access token alone isn't enough to turn 2FA off, and wrong
+	// codes here count towards the same lockout as logins
+	ok, err := checkSecondFactor(r.Context(), qtx, credential, params.Code)
+	if respondToTOTPLockout(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check the two-factor code")
+		return
+	}
+	if !ok {
+		err = tx.Commit()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check the two-factor code")
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	err = qtx.DeleteTotpCredential(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication")
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't delete the recovery codes")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't disable two-factor authentication")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loginTwoFactorHandler finishes a login for a user with 2FA enabled by
+// exchanging the challenge from loginUserHandler and a code for tokens. Each
+// challenge only allows a few wrong codes, and since anyone with the password
+// can start new challenges, wrong codes are also counted per user: past a
+// few in a row, 2FA logins are locked with a growing backoff.
+func (cfg *apiConfig) loginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	params := twoFactorLogin{}
+	if !decodeRequest(w, r, &params) {
+		return
+	}
+
+	ctx := r.Context()
+	tx, err := cfg.conn.BeginTx(ctx, nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't finish the login")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	challenge, err := qtx.GetLoginChallengeForUpdate(ctx, auth.HashRefreshToken(params.ChallengeToken))
+	if err != nil || !challenge.ExpiresAt.After(time.Now()) {
+		respondWithError(w, http.StatusUnauthorized, "Login challenge is invalid or expired")
+		return
+	}
+
+	if challenge.Attempts >= maxLoginChallengeAttempts {
+		err = qtx.DeleteLoginChallenge(ctx, challenge.TokenHash)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't finish the login")
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Too many wrong codes; log in again")
+		return
+	}
+
+	// Locking the credential makes concurrent guesses from different
+	// challenges take turns, so none of them slip past the lockout
+	credential, err := qtx.GetTotpCredentialForUpdate(ctx, challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Two-factor authentication isn't enabled for this user")
+		return
+	}
+
+	ok, err := checkSecondFactor(ctx, qtx, credential, params.Code)
+	if respondToTOTPLockout(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check the two-factor code")
+		return
+	}
+	if !ok {
+		err = qtx.RecordLoginChallengeFailure(ctx, challenge.TokenHash)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't finish the login")
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Invalid two-factor code")
+		return
+	}
+
+	err = qtx.DeleteLoginChallenge(ctx, challenge.TokenHash)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't finish the login")
+		return
+	}
+
+	user, err := qtx.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this challenge no longer exists")
+		return
+	}
+
+	addedUser, err := cfg.startSession(ctx, qtx, r, user)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start a session for the user")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't finish the login")
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, addedUser)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto/sha1"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestTOTPLockout(t *testing.T) {
+	tests := []struct {
+		failures int32
+		want     time.Duration
+	}{
+		{1, 0},
+		{totpFreeFailures - 1, 0},
+		{totpFreeFailures, time.Minute},
+		{totpFreeFailures + 1, 2 * time.Minute},
+		{totpFreeFailures + 3, 8 * time.Minute},
+		{totpFreeFailures + 6, totpMaxLockout},
+		{1000, totpMaxLockout},
+	}
+
+	for _, tt := range tests {
+		if got := totpLockout(tt.failures); got != tt.want {
+			t.Errorf("totpLockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestDisableTwoFactorHandler(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	currentCode := auth.TOTPAt(key, auth.TOTPStep(time.Now()), 6, sha1.New)
+	// Not digits, so it can't happen to match a step near now
+	wrongCode := "12345x"
+
+	tests := []struct {
+		name           string
+		code           string
+		failedAttempts int32
+		lockedUntil    sql.NullTime
+		want           int
+		wantFailures   driver.Value
+		wantLocked     bool
+		wantDisabled   bool
+	}{
+		{name: "Right code", code: currentCode, failedAttempts: 2, want: http.StatusNoContent, wantDisabled: true},
+		{name: "Wrong code", code: wrongCode, want: http.StatusUnauthorized, wantFailures: int64(1)},
+		{name: "Wrong code past the free failures", code: wrongCode, failedAttempts: totpFreeFailures - 1, want: http.StatusUnauthorized, wantFailures: int64(totpFreeFailures), wantLocked: true},
+		{name: "Locked out", code: currentCode, failedAttempts: totpFreeFailures, lockedUntil: sql.NullTime{Time: time.Now().Add(time.Minute), Valid: true}, want: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			userID := uuid.New()
+
+			var recorded []driver.Value
+			db := useFakeDB(t, cfg, map[string]fakeQuery{
+				"GetTotpCredentialForUpdate": rows([]driver.Value{
+					userID.String(), secret, time.Now(), time.Now(), int64(0),
+					int64(tt.failedAttempts), nullValue(tt.lockedUntil),
+				}),
+				"UseTotpStep":     affected(1),
+				"UseRecoveryCode": affected(0),
+				"RecordTotpFailure": func(args []driver.Value) (fakeResult, error) {
+					recorded = args
+					return fakeResult{}, nil
+				},
+				"ResetTotpFailures":    affected(1),
+				"DeleteTotpCredential": affected(1),
+				"DeleteRecoveryCodes":  affected(10),
+			})
+
+			r := httptest.NewRequest("POST", "/api/2fa/disable", strings.NewReader(`{"code": "`+tt.code+`"}`))
+			r = r.WithContext(withPrincipal(r.Context(), auth.AccessToken{UserID: userID, Role: auth.RoleUser}))
+			w := httptest.NewRecorder()
+			cfg.disableTwoFactorHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if db.Ran("DeleteTotpCredential") != tt.wantDisabled {
+				t.Errorf("DeleteTotpCredential ran = %v, want %v", db.Ran("DeleteTotpCredential"), tt.wantDisabled)
+			}
+			if tt.want == http.StatusTooManyRequests {
+				if w.Header().Get("Retry-After") == "" {
+					t.Error("Expected a Retry-After header")
+				}
+				if db.Ran("UseTotpStep") || db.Ran("UseRecoveryCode") {
+					t.Error("Expected the code not to be checked while locked out")
+				}
+			}
+			if tt.wantFailures == nil {
+				if recorded != nil {
+					t.Errorf("RecordTotpFailure ran with %v, want it not to run", recorded)
+				}
+				return
+			}
+			// The failure has to be committed, or the wrong code wouldn't count
+			if recorded == nil || !db.Ran("COMMIT") {
+				t.Fatal("Expected the wrong code to be recorded and committed")
+			}
+			if recorded[1] != tt.wantFailures || (recorded[2] != nil) != tt.wantLocked {
+				t.Errorf("RecordTotpFailure(failures %v, locked until %v), want %v failures, locked %v", recorded[1], recorded[2], tt.wantFailures, tt.wantLocked)
+			}
+		})
+	}
+}
@@ -2,75 +2,181 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/ericksotoe/chirpy/internal/auth"
 	"github.com/ericksotoe/chirpy/internal/database"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
 type UserWithToken struct {
-	ID           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	Token        string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Username      *string   `json:"username"`
+	Token         string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
 }
 
 type emailAndPassword struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,password"`
+	Username string `json:"username" validate:"username"`
+}
+
+// loginCredentials skips the password policy, so accounts created before it
+// existed can still log in.
+type loginCredentials struct {
+	Email    string `json:"email" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// userUpdate is the body of PUT /api/users, which replaces the password and
+// optionally the email and username. Like PATCH, it needs the current
+// password as well.
+type userUpdate struct {
+	Email           string `json:"email" validate:"email"`
+	Password        string `json:"password" validate:"required,password"`
+	CurrentPassword string `json:"current_password"`
+	Username        string `json:"username" validate:"username"`
 }
 
 type responseToken struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 type UserResponse struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  *string   `json:"pending_email"`
+	Username      *string   `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Role          string    `json:"role"`
+}
+
+func userResponseFromDB(user database.User) UserResponse {
+	return UserResponse{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		PendingEmail:  nullStringPtr(user.PendingEmail),
+		Username:      nullStringPtr(user.Username),
+		DisplayName:   user.DisplayName,
+		Bio:           user.Bio,
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+	}
+}
+
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+// requestedUsername normalizes an optional username from a request body
+// that already passed the username rule. An empty username means the caller
+// didn't ask to set one.
+func requestedUsername(username string) sql.NullString {
+	if username == "" {
+		return sql.NullString{}
+	}
+	normalized, _ := normalizeUsername(username)
+	return sql.NullString{String: normalized, Valid: true}
+}
+
+// Unique constraints on users, as Postgres names them.
+const (
+	usersEmailKey    = "users_email_key"
+	usersUsernameKey = "users_username_key"
+)
+
+// isUniqueViolation reports whether err is a violation of the unique
+// constraint named constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+// respondToUserConflict answers 409 when err comes from saving a user whose
+// email or username is already taken, and reports whether it did.
+func respondToUserConflict(w http.ResponseWriter, err error) bool {
+	switch {
+	case isUniqueViolation(err, usersEmailKey):
+		respondWithError(w, http.StatusConflict, "Email is already in use")
+		return true
+	case isUniqueViolation(err, usersUsernameKey):
+		respondWithError(w, http.StatusConflict, "Username is already taken")
+		return true
+	default:
+		return false
+	}
 }
 
 func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
 	userEmailAndPassword := emailAndPassword{}
-	err := decoder.Decode(&userEmailAndPassword)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error reading the password and email from the request body")
+	if !decodeRequest(w, r, &userEmailAndPassword) {
 		return
 	}
 
+	username := requestedUsername(userEmailAndPassword.Username)
+
 	hash, err := auth.HashPassword(userEmailAndPassword.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, "Couldn't hash the password")
 		return
 	}
 	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
 		Email:          userEmailAndPassword.Email,
 		HashedPassword: hash,
+		Username:       username,
 	})
+	if respondToUserConflict(w, err) {
+		return
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create the user")
 		return
 	}
 
+	err = cfg.sendVerificationEmail(user.ID, user.Email)
+	if err != nil {
+		log.Printf("Error sending the verification email: %v", err)
+	}
+
 	addedUser := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed}
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Username:      nullStringPtr(user.Username),
+		IsChirpyRed:   user.IsChirpyRed}
 
 	res, err := json.Marshal(addedUser)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+		respondWithError(w, http.StatusInternalServerError, "Couldn't encode the user")
 		return
 	}
 
@@ -81,11 +187,8 @@ func (cfg *apiConfig) createUserHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *apiConfig) loginUserHandler(w http.ResponseWriter, r *http.Request) {
-	decoder := json.NewDecoder(r.Body)
-	userEmailAndPassword := emailAndPassword{}
-	err := decoder.Decode(&userEmailAndPassword)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Something went wrong")
+	userEmailAndPassword := loginCredentials{}
+	if !decodeRequest(w, r, &userEmailAndPassword) {
 		return
 	}
 
@@ -107,41 +210,78 @@ func (cfg *apiConfig) loginUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	enabled, err := cfg.twoFactorEnabled(ctx, user.ID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
+		respondWithError(w, http.StatusInternalServerError, "Couldn't check the user's two-factor settings")
+		return
+	}
+	if enabled {
+		challenge, err := cfg.createLoginChallenge(ctx, user.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't start the two-factor login")
+			return
+		}
+		respondWithJSON(w, http.StatusOK, challenge)
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	addedUser, err := cfg.startSession(ctx, cfg.db, r, user)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start a session for the user")
 		return
 	}
-	sixtyDays := (time.Hour * 24) * 60
+
+	respondWithJSON(w, http.StatusOK, addedUser)
+}
+
+// startSession issues the access and refresh tokens for a new session, once
+// the user has proven who they are. Logging in also cancels a pending account
+// deletion.
+func (cfg *apiConfig) startSession(ctx context.Context, q *database.Queries, r *http.Request, user database.User) (UserWithToken, error) {
+	_, err := q.CancelAccountDeletion(ctx, user.ID)
+	if err != nil {
+		return UserWithToken{}, err
+	}
+
+	sessionID := uuid.New()
+	token, err := auth.MakeSessionJWT(user.ID, sessionID, auth.Role(user.Role), cfg.jwtKeys, time.Hour)
+	if err != nil {
+		return UserWithToken{}, err
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return UserWithToken{}, err
+	}
 	params := database.CreateRefreshTokenParams{
-		Token:     refreshToken,
+		Token:     auth.HashRefreshToken(refreshToken),
 		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(sixtyDays),
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		FamilyID:  sessionID,
+		UserAgent: clientUserAgent(r),
+		IpAddress: clientIP(r),
 	}
-	_, err = cfg.db.CreateRefreshToken(ctx, params)
+	_, err = q.CreateRefreshToken(ctx, params)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, err.Error())
-		return
+		return UserWithToken{}, err
 	}
 
-	addedUser := UserWithToken{
-		ID:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		IsChirpyRed:  user.IsChirpyRed,
-		Token:        token,
-		RefreshToken: refreshToken}
-
-	respondWithJSON(w, http.StatusOK, addedUser)
+	return UserWithToken{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		Username:      nullStringPtr(user.Username),
+		IsChirpyRed:   user.IsChirpyRed,
+		Role:          user.Role,
+		Token:         token,
+		RefreshToken:  refreshToken}, nil
 }
 
+// refreshTokenHandler rotates the presented refresh token. The old token is
+// revoked and linked to its replacement; presenting it again afterwards is
+// treated as theft and revokes every token in its family.
 func (cfg *apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
@@ -149,20 +289,89 @@ func (cfg *apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	user, err := cfg.db.GetUserFromRefreshToken(context.Background(), refreshToken)
+	ctx := r.Context()
+	tx, err := cfg.conn.BeginTx(ctx, nil)
 	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't refresh the token")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	storedToken, err := qtx.GetRefreshTokenForUpdate(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "The user's token has been rejected or doesn't exist")
+		return
+	}
+
+	if storedToken.ReplacedBy.Valid {
+		err = qtx.RevokeRefreshTokenFamily(ctx, storedToken.FamilyID)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the reused token's family")
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "Refresh token was already used; all sessions from this login have been revoked")
+		return
+	}
+
+	if storedToken.RevokedAt.Valid || !storedToken.ExpiresAt.After(time.Now()) {
 		respondWithError(w, http.StatusUnauthorized, "The user's token has been rejected or doesn't exist")
 		return
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	newRefreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can't create a new refresh token for the user")
+		return
+	}
+
+	_, err = qtx.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     auth.HashRefreshToken(newRefreshToken),
+		UserID:    storedToken.UserID,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+		FamilyID:  storedToken.FamilyID,
+		UserAgent: storedToken.UserAgent,
+		IpAddress: storedToken.IpAddress,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can't save the new refresh token")
+		return
+	}
+
+	err = qtx.MarkRefreshTokenReplaced(ctx, database.MarkRefreshTokenReplacedParams{
+		Token:      storedToken.Token,
+		ReplacedBy: sql.NullString{String: auth.HashRefreshToken(newRefreshToken), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Can't revoke the old refresh token")
+		return
+	}
+
+	// The role is read again so a role change reaches the next access token
+	user, err := qtx.GetUserByID(ctx, storedToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
+		return
+	}
+
+	token, err := auth.MakeSessionJWT(user.ID, storedToken.FamilyID, auth.Role(user.Role), cfg.jwtKeys, time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Can't create a new JWT for the user")
 		return
 	}
 
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't refresh the token")
+		return
+	}
+
 	jsonToken := responseToken{
-		Token: token,
+		Token:        token,
+		RefreshToken: newRefreshToken,
 	}
 
 	respondWithJSON(w, http.StatusOK, jsonToken)
@@ -175,7 +384,7 @@ func (cfg *apiConfig) revokeTokenHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	_, err = cfg.db.RevokeToken(context.Background(), refreshToken)
+	_, err = cfg.db.RevokeToken(context.Background(), auth.HashRefreshToken(refreshToken))
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "Refresh token was not found in the db")
 		return
@@ -185,23 +394,24 @@ func (cfg *apiConfig) revokeTokenHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Access token is malformed or missing")
+	accessToken := requestPrincipal(r)
+
+	userEmailAndPassword := userUpdate{}
+	if !decodeRequest(w, r, &userEmailAndPassword) {
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	username := requestedUsername(userEmailAndPassword.Username)
+
+	userID := accessToken.UserID
+	currentUser, err := cfg.db.GetUserByID(r.Context(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "malformed / bad signature / expired token")
+		respondWithError(w, http.StatusUnauthorized, "User for this token no longer exists")
 		return
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	userEmailAndPassword := emailAndPassword{}
-	err = decoder.Decode(&userEmailAndPassword)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Error reading the password and email from the request body")
+	match, _ := auth.CheckPasswordHash(userEmailAndPassword.CurrentPassword, currentUser.HashedPassword)
+	if !match {
+		respondWithError(w, http.StatusForbidden, "current_password is required to change the email or password")
 		return
 	}
 
@@ -210,27 +420,75 @@ func (cfg *apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusInternalServerError, "Error hashing the password passed in")
 		return
 	}
+	// Hashes are salted, so the new password is checked against the old hash
+	// instead of comparing the two hashes
+	samePassword, _ := auth.CheckPasswordHash(userEmailAndPassword.Password, currentUser.HashedPassword)
+
+	// A new email address only replaces the current one once it's verified;
+	// until then it's kept as pending and the current address keeps working
+	pendingEmail := sql.NullString{}
+	if userEmailAndPassword.Email != "" && userEmailAndPassword.Email != currentUser.Email {
+		inUse, err := cfg.emailInUse(r.Context(), userEmailAndPassword.Email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't check whether the email is in use")
+			return
+		}
+		if inUse {
+			respondWithError(w, http.StatusConflict, "Email is already in use")
+			return
+		}
+		pendingEmail = sql.NullString{String: userEmailAndPassword.Email, Valid: true}
+	}
 
 	params := database.UpdateUserPassEmailParams{
 		ID:             userID,
-		Email:          userEmailAndPassword.Email,
 		HashedPassword: hashedPass,
+		PendingEmail:   pendingEmail,
+		Username:       username,
+	}
+
+	tx, err := cfg.conn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating the users email and password")
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	responseUser, err := qtx.UpdateUserPassEmail(r.Context(), params)
+	if respondToUserConflict(w, err) {
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Error updating the users email and password")
+		return
+	}
+
+	// A new password logs out every other device, in case the old one leaked
+	if !samePassword {
+		err = qtx.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+			UserID:   userID,
+			FamilyID: accessToken.SessionID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't revoke the user's other sessions")
+			return
+		}
 	}
 
-	responseUser, err := cfg.db.UpdateUserPassEmail(context.Background(), params)
+	err = tx.Commit()
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Error updating the users email and password")
 		return
 	}
 
-	response := UserResponse{
-		ID:          responseUser.ID,
-		CreatedAt:   responseUser.CreatedAt,
-		UpdatedAt:   responseUser.UpdatedAt,
-		Email:       responseUser.Email,
-		IsChirpyRed: responseUser.IsChirpyRed,
+	if pendingEmail.Valid && pendingEmail != currentUser.PendingEmail {
+		err = cfg.sendVerificationEmail(userID, pendingEmail.String)
+		if err != nil {
+			log.Printf("Error sending the verification email: %v", err)
+		}
 	}
 
-	respondWithJSON(w, http.StatusOK, response)
+	respondWithJSON(w, http.StatusOK, userResponseFromDB(responseUser))
 
 }
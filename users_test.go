@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ericksotoe/chirpy/internal/auth"
+	"github.com/ericksotoe/chirpy/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+func TestRespondToUserConflict(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantDetail string
+	}{
+		{name: "Duplicate email", err: &pq.Error{Code: "23505", Constraint: usersEmailKey}, wantDetail: "Email is already in use"},
+		{name: "Duplicate username", err: fmt.Errorf("creating user: %w", &pq.Error{Code: "23505", Constraint: usersUsernameKey}), wantDetail: "Username is already taken"},
+		{name: "Other constraint", err: &pq.Error{Code: "23505", Constraint: "users_pkey"}},
+		{name: "Not a conflict", err: errors.New("connection refused")},
+		{name: "No error", err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handled := respondToUserConflict(w, tt.err)
+			if handled != (tt.wantDetail != "") {
+				t.Fatalf("respondToUserConflict() = %v, want %v", handled, tt.wantDetail != "")
+			}
+			if !handled {
+				return
+			}
+			if w.Code != http.StatusConflict {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+			}
+			if problem := decodeProblem(t, w); problem.Detail != tt.wantDetail {
+				t.Errorf("detail = %q, want %q", problem.Detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestUpdateUserHandlerRequiresCurrentPassword(t *testing.T) {
+	hashedPassword, err := auth.HashPassword("old-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := database.User{ID: uuid.New(), Email: "walt@example.com", HashedPassword: hashedPassword, Role: "user"}
+
+	tests := []struct {
+		name        string
+		body        string
+		want        int
+		wantUpdated bool
+	}{
+		{name: "No current password", body: `{"password": "new-password"}`, want: http.StatusForbidden},
+		{name: "Wrong current password", body: `{"password": "new-password", "current_password": "guess"}`, want: http.StatusForbidden},
+		{name: "Current password", body: `{"password": "new-password", "current_password": "old-password"}`, want: http.StatusOK, wantUpdated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t)
+			db := useFakeDB(t, cfg, map[string]fakeQuery{
+				"GetUserByID":         rows(userRow(user)),
+				"UpdateUserPassEmail": rows(userRow(user)),
+				"RevokeOtherSessions": affected(1),
+			})
+
+			r := httptest.NewRequest("PUT", "/api/users", strings.NewReader(tt.body))
+			r = r.WithContext(withPrincipal(context.Background(), auth.AccessToken{UserID: user.ID, SessionID: uuid.New(), Role: auth.RoleUser}))
+			w := httptest.NewRecorder()
+			cfg.updateUserHandler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if db.Ran("UpdateUserPassEmail") != tt.wantUpdated {
+				t.Errorf("UpdateUserPassEmail ran = %v, want %v", !tt.wantUpdated, tt.wantUpdated)
+			}
+		})
+	}
+}
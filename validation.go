@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxJSONBodyBytes  = 1 << 20
+	minPasswordLength = 8
+	maxPasswordLength = 128
+)
+
+// decodeRequest reads a JSON request body into dst and validates it against
+// the validate tags on dst's fields. Bodies over maxJSONBodyBytes, unknown
+// fields and anything after the JSON value are rejected, and every field
+// that fails validation is reported at once. It writes the error response
+// itself and reports whether the handler should continue.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return decodeBody(w, r, dst, true)
+}
+
+// decodeWebhook is decodeRequest for payloads we don't control, which may
+// gain fields at any time, so unknown fields are ignored.
+func decodeWebhook(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return decodeBody(w, r, dst, false)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, dst any, strict bool) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(dst)
+	if err == nil {
+		// A second value, or garbage after the first, means the body isn't
+		// the single JSON object the handler expects
+		err = decoder.Decode(&struct{}{})
+		if errors.Is(err, io.EOF) {
+			err = nil
+		} else if err == nil {
+			err = errors.New("trailing data")
+		}
+		if err != nil && !isMaxBytesError(err) {
+			respondWithProblem(w, malformedBody("Request body must be a single JSON value"))
+			return false
+		}
+	}
+	if err != nil {
+		if isMaxBytesError(err) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body can be at most %d bytes", maxJSONBodyBytes))
+			return false
+		}
+		respondWithProblem(w, malformedBody(describeDecodeError(err)))
+		return false
+	}
+
+	fields := validateStruct(dst)
+	if len(fields) > 0 {
+		respondWithProblem(w, &apiError{
+			Status: http.StatusBadRequest,
+			Code:   codeValidationFailed,
+			Detail: "The request body failed validation",
+			Fields: fields,
+		})
+		return false
+	}
+	return true
+}
+
+func isMaxBytesError(err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	return errors.As(err, &maxBytesErr)
+}
+
+// describeDecodeError turns encoding/json errors into messages that make
+// sense to API clients.
+func describeDecodeError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return "Request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "Request body is truncated JSON"
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("Request body has malformed JSON at byte %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("Field %q must be a %s", typeErr.Field, jsonTypeName(typeErr.Type))
+	case errors.As(err, &typeErr):
+		return "Request body must be a JSON object"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "Request body has an unknown field " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	default:
+		return "Couldn't decode the request body"
+	}
+}
+
+func jsonTypeName(t reflect.Type) string {
+	if t == reflect.TypeFor[uuid.UUID]() {
+		return "UUID"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// validateStruct checks the fields of the struct v points to against their
+// validate tags, such as `validate:"required,max=140"`, and returns a
+// fieldError for each field that fails. Fields are named after their JSON
+// keys. The rules are:
+//
+//	required  the field must be present and not empty
+//	email     a bare email address, like walt@example.com
+//	password  the password policy: 8 to 128 characters
+//	username  3-30 letters, numbers or underscores
+//	uuid      a UUID
+//	max=N     at most N characters, or N items for a list
+//
+// Other than required, rules only apply to fields the client sent, so an
+// optional field can be left out or, for non-pointer fields, left empty.
+func validateStruct(v any) []fieldError {
+	return validateFields(reflect.Indirect(reflect.ValueOf(v)), "")
+}
+
+func validateFields(value reflect.Value, prefix string) []fieldError {
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []fieldError
+	structType := value.Type()
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := prefix + jsonFieldName(field)
+		fieldValue := value.Field(i)
+
+		if fieldValue.Kind() == reflect.Struct && field.Type != reflect.TypeFor[uuid.UUID]() {
+			fields = append(fields, validateFields(fieldValue, name+".")...)
+		}
+
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			message := checkRule(rule, fieldValue)
+			if message != "" {
+				fields = append(fields, fieldError{Field: name, Message: name + " " + message})
+				break
+			}
+		}
+	}
+	return fields
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// checkRule returns why value breaks rule, or "" if it doesn't. The reason
+// reads as a sentence once the field name is put in front of it.
+func checkRule(rule string, value reflect.Value) string {
+	if rule == "required" {
+		if isEmptyValue(value) {
+			return "is required"
+		}
+		return ""
+	}
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	} else if value.IsZero() {
+		return ""
+	}
+
+	switch {
+	case rule == "email":
+		return checkEmail(value.String())
+	case rule == "password":
+		return checkPassword(value.String())
+	case rule == "username":
+		_, err := normalizeUsername(value.String())
+		if err != nil {
+			return "must be 3-30 letters, numbers or underscores"
+		}
+		return ""
+	case rule == "uuid":
+		if uuid.Validate(value.String()) != nil {
+			return "must be a UUID"
+		}
+		return ""
+	case strings.HasPrefix(rule, "max="):
+		limit, err := strconv.Atoi(strings.TrimPrefix(rule, "max="))
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad rule %q", rule))
+		}
+		return checkMax(value, limit)
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", rule))
+	}
+}
+
+func isEmptyValue(value reflect.Value) bool {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return true
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+func checkEmail(email string) string {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email, "@") {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+// checkPassword enforces the password policy. Length is counted in
+// characters, so passphrases in any script are judged the same way.
+func checkPassword(password string) string {
+	length := utf8.RuneCountInString(password)
+	if length < minPasswordLength || length > maxPasswordLength {
+		return fmt.Sprintf("must be between %d and %d characters", minPasswordLength, maxPasswordLength)
+	}
+	if strings.TrimSpace(password) == "" {
+		return "can't be only whitespace"
+	}
+	return ""
+}
+
+// checkMax limits strings by Unicode characters rather than bytes, so a
+// chirp of 140 emoji is as valid as one of 140 letters.
+func checkMax(value reflect.Value, limit int) string {
+	switch value.Kind() {
+	case reflect.String:
+		if utf8.RuneCountInString(value.String()) > limit {
+			return fmt.Sprintf("can be at most %d characters", limit)
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if value.Len() > limit {
+			return fmt.Sprintf("can have at most %d items", limit)
+		}
+	default:
+		panic(fmt.Sprintf("validate: max doesn't apply to %s", value.Kind()))
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeTestRequest(body string, dst any) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/api/test", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	if decodeRequest(w, r, dst) {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w
+}
+
+func TestDecodeRequestChirpLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wantOK bool
+	}{
+		{name: "140 letters", body: strings.Repeat("a", 140), wantOK: true},
+		{name: "140 emoji", body: strings.Repeat("🐦", 140), wantOK: true},
+		{name: "141 letters", body: strings.Repeat("a", 141), wantOK: false},
+		{name: "141 accented letters", body: strings.Repeat("é", 141), wantOK: false},
+		{name: "Empty", body: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := decodeTestRequest(`{"body": "`+tt.body+`"}`, &parameters{})
+			if got := w.Code == http.StatusOK; got != tt.wantOK {
+				t.Errorf("accepted = %v, want %v (status %d, body %s)", got, tt.wantOK, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestReportsEveryField(t *testing.T) {
+	w := decodeTestRequest(`{"email": "not-an-email", "password": "short", "username": "a!"}`, &emailAndPassword{})
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	problem := decodeProblem(t, w)
+	if problem.Code != codeValidationFailed {
+		t.Errorf("code = %q, want %q", problem.Code, codeValidationFailed)
+	}
+	var fields []string
+	for _, fieldErr := range problem.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	if got := strings.Join(fields, ","); got != "email,password,username" {
+		t.Errorf("fields = %s, want email,password,username", got)
+	}
+}
+
+func TestDecodeRequestOptionalFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		wantOK bool
+	}{
+		{name: "Only the bio", body: `{"bio": "Hi"}`, wantOK: true},
+		{name: "Empty bio", body: `{"bio": ""}`, wantOK: true},
+		{name: "Empty email", body: `{"email": ""}`, wantOK: false},
+		{name: "Short password", body: `{"password": "hunter2"}`, wantOK: false},
+		{name: "Long display name", body: `{"display_name": "` + strings.Repeat("x", 51) + `"}`, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := decodeTestRequest(tt.body, &userPatch{})
+			if got := w.Code == http.StatusOK; got != tt.wantOK {
+				t.Errorf("accepted = %v, want %v (status %d, body %s)", got, tt.wantOK, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestRejectsMalformedBodies(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "Unknown field", body: `{"body": "hi", "author": "walt"}`, wantStatus: http.StatusBadRequest},
+		{name: "Trailing data", body: `{"body": "hi"} {"body": "again"}`, wantStatus: http.StatusBadRequest},
+		{name: "Trailing garbage", body: `{"body": "hi"}x`, wantStatus: http.StatusBadRequest},
+		{name: "Too large", body: `{"body": "` + strings.Repeat("a", maxJSONBodyBytes) + `"}`, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := decodeTestRequest(tt.body, &parameters{})
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusBadRequest {
+				if problem := decodeProblem(t, w); problem.Code != codeMalformedBody {
+					t.Errorf("code = %q, want %q", problem.Code, codeMalformedBody)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeWebhookAllowsUnknownFields(t *testing.T) {
+	body := `{"event": "user.upgraded", "data": {"user_id": "3311741c-680c-4546-99f3-fc9efac2036c", "plan": "yearly"}}`
+	r := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	if !decodeWebhook(w, r, &UpgradeEvent{}) {
+		t.Fatalf("decodeWebhook() rejected the event: %s", w.Body)
+	}
+
+	r = httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(`{"event": "user.upgraded", "data": {"user_id": "42"}}`))
+	w = httptest.NewRecorder()
+	if decodeWebhook(w, r, &UpgradeEvent{}) {
+		t.Fatal("decodeWebhook() accepted an invalid user_id")
+	}
+	problem := decodeProblem(t, w)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "data.user_id" {
+		t.Errorf("problem.Errors = %+v, want one error for data.user_id", problem.Errors)
+	}
+}